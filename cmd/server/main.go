@@ -11,17 +11,22 @@ import (
 	"time"
 
 	"github.com/Hamid207/ai-code-test1/internal/handler"
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
 	"github.com/Hamid207/ai-code-test1/internal/repository"
 	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/Hamid207/ai-code-test1/pkg/apple"
+	"github.com/Hamid207/ai-code-test1/pkg/captcha"
 	"github.com/Hamid207/ai-code-test1/pkg/config"
 	"github.com/Hamid207/ai-code-test1/pkg/database"
+	"github.com/Hamid207/ai-code-test1/pkg/encryption"
+	"github.com/Hamid207/ai-code-test1/pkg/google"
 	"github.com/Hamid207/ai-code-test1/pkg/jwt"
 	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"github.com/Hamid207/ai-code-test1/pkg/oidc"
 	redispkg "github.com/Hamid207/ai-code-test1/pkg/redis"
 	"github.com/gin-gonic/gin"
-	"github.com/ulule/limiter/v3"
-	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
-	"github.com/ulule/limiter/v3/drivers/store/memory"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
@@ -68,26 +73,120 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(dbPool)
+	identityRepo := repository.NewIdentityRepository(dbPool)
 	tokenRepo := repository.NewTokenRepository(dbPool)
+	roleRepo := repository.NewRoleRepository(dbPool)
 
 	// Initialize Redis repositories
-	// TODO: Integrate Redis repositories with services
-	_ = redispkg.NewTokenRepository(redisClient)
-	_ = redispkg.NewBlacklistRepository(redisClient)
-	_ = redispkg.NewRateLimitRepository(redisClient)
-	_ = redispkg.NewCacheRepository(redisClient)
+	redisTokenRepo := redispkg.NewTokenRepository(redisClient)
+	blacklistRepo := redispkg.NewBlacklistRepository(redisClient)
+	rateLimitRepo := redispkg.NewRateLimitRepository(redisClient)
+	cacheRepo := redispkg.NewCacheRepository(redisClient)
+	linkTicketRepo := redispkg.NewLinkTicketRepository(redisClient)
+	lockoutRepo := redispkg.NewLockoutRepository(redisClient)
+	webhookEventRepo := redispkg.NewWebhookEventRepository(redisClient)
+
+	// Users are read through Redis and, on each instance, a short-lived in-process cache
+	userStore := repository.NewCachedUserRepository(userRepo, cacheRepo, cfg.UserCacheTTL)
+	if cfg.UserCacheLocalSize > 0 {
+		userStore.WithLocalCache(cfg.UserCacheLocalSize, cfg.UserCacheLocalTTL)
+	}
 
 	// Initialize JWT token service
-	tokenService := jwt.NewTokenService(cfg.JWTSecret)
+	tokenService := jwt.NewTokenService(jwt.Config{
+		SecretKey:         hmacSecret(cfg),
+		Issuer:            cfg.JWTIssuer,
+		Audience:          cfg.JWTAudience,
+		AccessTokenTTL:    cfg.JWTAccessTokenTTL,
+		RefreshTokenTTL:   cfg.JWTRefreshTokenTTL,
+		Clients:           tokenClients(cfg),
+		LegacyClaimsUntil: cfg.JWTLegacyClaimsUntil,
+		HMACAcceptedUntil: cfg.JWTHMACAcceptedUntil,
+	})
+	signingKey, err := loadSigningKey(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	// Background jobs stop when the server shuts down
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// With a key encryption key, signing keys live in a database key ring shared by all
+	// instances and are rotated on schedule; otherwise the configured key signs forever
+	var keyRotationService *service.KeyRotationService
+	if cfg.JWTKeyEncryptionKey != "" {
+		keyRotationService, err = newKeyRotationService(cfg, dbPool, tokenService, signingKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize signing key rotation: %v", err)
+		}
+		go keyRotationService.Run(backgroundCtx)
+	} else if signingKey != nil {
+		tokenService.WithSigningKey(signingKey)
+	}
+
+	// Initialize identity provider verifiers (shared so public keys are fetched once)
+	appleVerifier := apple.NewVerifier(cfg.AppleClientID).WithNotificationMaxAge(cfg.AppleNotificationMaxAge)
+	googleVerifier := google.NewVerifier(cfg.GoogleClientID)
+	riscVerifier := google.NewRISCVerifier(cfg.GoogleRISCIssuer, cfg.GoogleRISCJWKSURL, cfg.GoogleClientID).
+		WithMaxAge(cfg.GoogleRISCMaxAge)
 
 	// Initialize services
-	authService := service.NewAuthService(cfg.AppleClientID, cfg.GoogleClientID, userRepo, tokenRepo, tokenService)
+	sessionService := service.NewSessionService(tokenRepo, blacklistRepo, tokenService).
+		WithRedisTokens(redisTokenRepo)
+	linkingPolicy := service.NewLinkingPolicy(cfg.LinkTrustedEmailDomains)
+	profileService := service.NewProfileService(userStore)
+	authService := service.NewAuthService(appleVerifier, googleVerifier, userStore, identityRepo, tokenRepo, roleRepo, tokenService, sessionService, linkTicketRepo, linkingPolicy, profileService)
+	oidcProviders := newIdentityProviders(cfg)
+	authService.WithIdentityProviders(oidcProviders...)
+	authService.WithRedisTokens(redisTokenRepo)
+	authService.WithLockout(newLockoutService(cfg, lockoutRepo))
+
+	// Apple token exchange and revocation need a client secret and an encryption key
+	// for storing Apple refresh tokens; without them account deletion skips Apple revocation
+	var appleTokenService *service.AppleTokenService
+	if cfg.HasAppleClientSecret() && cfg.AppleTokenEncryptionKey != "" {
+		appleTokenService, err = newAppleTokenService(cfg, dbPool)
+		if err != nil {
+			log.Fatalf("Failed to initialize Apple token service: %v", err)
+		}
+		authService.WithAppleTokens(appleTokenService)
+
+		// Catch revoked Apple authorizations whose consent-revoked notification never arrived
+		if cfg.AppleTokenValidationInterval > 0 {
+			appleTokenService.WithAccountValidation(sessionService, cfg.AppleTokenValidationInterval)
+			go appleTokenService.Run(backgroundCtx)
+		}
+	}
+	accountService := service.NewAccountService(userStore, sessionService, appleTokenService)
+	identityService := service.NewIdentityService(identityRepo, appleTokenService,
+		linkableProviders(cfg, appleVerifier, googleVerifier, oidcProviders)...)
+	roleService := service.NewRoleService(roleRepo, userStore, sessionService)
+	adminService := service.NewAdminService(userStore, identityRepo, tokenRepo, roleRepo, sessionService)
+	if keyRotationService != nil {
+		adminService.WithKeyRotation(keyRotationService)
+	}
+	webhookService := service.NewWebhookService(appleVerifier, riscVerifier, userStore, identityRepo, sessionService, accountService, appleTokenService, webhookEventRepo)
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService, dbPool)
+	handlers := &routeHandlers{
+		auth:     handler.NewAuthHandler(authService, dbPool),
+		session:  handler.NewSessionHandler(sessionService),
+		account:  handler.NewAccountHandler(accountService),
+		profile:  handler.NewProfileHandler(profileService),
+		role:     handler.NewRoleHandler(roleService),
+		admin:    handler.NewAdminHandler(adminService),
+		identity: handler.NewIdentityHandler(identityService),
+		webhook:  handler.NewWebhookHandler(webhookService),
+		jwks:     handler.NewJWKSHandler(tokenService),
+	}
+
+	// Initialize middleware
+	authMiddleware := middleware.NewAuthMiddleware(tokenService, blacklistRepo).WithUserStatus(profileService)
+	rateLimiter := middleware.NewRateLimiter(rateLimitRepo, rateLimitPolicies(cfg)...)
 
 	// Setup router
-	router := setupRouter(authHandler, cfg)
+	router := setupRouter(handlers, authMiddleware, rateLimiter, cfg)
 
 	// Create HTTP server
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
@@ -110,6 +209,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopBackground()
 
 	// Give outstanding requests 5 seconds to complete
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -132,8 +232,179 @@ func main() {
 	log.Println("Server exited gracefully")
 }
 
+// tokenClients converts the JWT_CLIENTS overrides to token service client configuration
+func tokenClients(cfg *config.Config) map[string]jwt.ClientConfig {
+	clients := make(map[string]jwt.ClientConfig, len(cfg.JWTClients))
+	for _, client := range cfg.JWTClients {
+		clients[client.Name] = jwt.ClientConfig{
+			AccessTokenTTL:  client.AccessTokenTTL,
+			RefreshTokenTTL: client.RefreshTokenTTL,
+			Secret:          client.Secret,
+		}
+	}
+	return clients
+}
+
+// rateLimitPolicies converts the configured rate limit policies
+func rateLimitPolicies(cfg *config.Config) []middleware.RatePolicy {
+	policies := make([]middleware.RatePolicy, 0, len(cfg.RateLimitPolicies))
+	for _, policy := range cfg.RateLimitPolicies {
+		policies = append(policies, middleware.RatePolicy{
+			Name:      policy.Name,
+			Algorithm: model.RateLimitAlgorithm(policy.Algorithm),
+			Limit:     int64(policy.Limit),
+			Period:    policy.Period,
+			PerUser:   policy.Key == config.RateLimitKeyUser,
+		})
+	}
+	return policies
+}
+
+// newLockoutService creates the brute-force protection for sign-in and refresh
+// CAPTCHAs are only required if a siteverify secret is configured
+func newLockoutService(cfg *config.Config, lockoutRepo repository.RedisLockoutRepository) *service.LockoutService {
+	lockoutService := service.NewLockoutService(lockoutRepo, service.LockoutPolicy{
+		IPThreshold:      int64(cfg.LockoutIPThreshold),
+		SubjectThreshold: int64(cfg.LockoutSubjectThreshold),
+		Window:           cfg.LockoutWindow,
+		Duration:         cfg.LockoutDuration,
+		MaxDuration:      cfg.LockoutMaxDuration,
+		CaptchaThreshold: int64(cfg.CaptchaThreshold),
+	})
+	if cfg.CaptchaSecret != "" {
+		lockoutService.WithCaptcha(captcha.NewVerifier(cfg.CaptchaVerifyURL, cfg.CaptchaSecret))
+	}
+	return lockoutService
+}
+
+// hmacSecret returns JWT_SECRET, unless an asymmetric key signs and HS256 tokens are no longer accepted
+// The secret is not part of the key ring: it signs only until the first key does, and verifies
+// tokens issued before the switch until JWT_HMAC_ACCEPTED_UNTIL. Past that it is not loaded at all.
+func hmacSecret(cfg *config.Config) string {
+	if cfg.JWTSigningKeyPath == "" && cfg.JWTKeyEncryptionKey == "" {
+		return cfg.JWTSecret
+	}
+	if cfg.JWTSecret != "" && !time.Now().Before(cfg.JWTHMACAcceptedUntil) {
+		log.Printf("Ignoring JWT_SECRET: HS256 tokens are no longer accepted (JWT_HMAC_ACCEPTED_UNTIL)")
+		return ""
+	}
+	return cfg.JWTSecret
+}
+
+// loadSigningKey loads the key configured in JWT_SIGNING_KEY_PATH, or returns nil if none is
+func loadSigningKey(cfg *config.Config) (*jwt.SigningKey, error) {
+	if cfg.JWTSigningKeyPath == "" {
+		return nil, nil
+	}
+
+	signingKey, err := jwt.LoadSigningKey(cfg.JWTSigningKeyPath, cfg.JWTSigningKeyID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %s signing key %s", signingKey.Method.Alg(), signingKey.ID)
+
+	return signingKey, nil
+}
+
+// newKeyRotationService loads the database key ring into the token service
+// initialKey becomes the first key of an empty ring; if nil one is generated
+func newKeyRotationService(cfg *config.Config, dbPool *pgxpool.Pool, tokenService *jwt.TokenService, initialKey *jwt.SigningKey) (*service.KeyRotationService, error) {
+	cipher, err := encryption.NewCipherFromBase64(cfg.JWTKeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize key encryption: %w", err)
+	}
+
+	ring := jwt.NewKeyRing()
+	tokenService.WithKeyRing(ring)
+
+	keyRotationService := service.NewKeyRotationService(
+		repository.NewSigningKeyRepository(dbPool, cipher),
+		ring,
+		cfg.JWTKeyAlgorithm,
+		cfg.JWTKeyRotationInterval,
+		cfg.JWTKeyPublishDelay,
+		tokenService.MaxTokenTTL(),
+	)
+
+	initCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := keyRotationService.Initialize(initCtx, initialKey); err != nil {
+		return nil, err
+	}
+	log.Printf("Signing key ring loaded (rotation interval: %s, publish delay: %s)", cfg.JWTKeyRotationInterval, cfg.JWTKeyPublishDelay)
+
+	return keyRotationService, nil
+}
+
+// newAppleTokenService builds the Apple REST client and encrypted token store
+func newAppleTokenService(cfg *config.Config, dbPool *pgxpool.Pool) (*service.AppleTokenService, error) {
+	var clientSecret apple.ClientSecretSource = apple.StaticClientSecret(cfg.AppleClientSecret)
+	if cfg.ApplePrivateKeyPath != "" {
+		generator, err := apple.NewClientSecretGeneratorFromFile(cfg.AppleTeamID, cfg.AppleClientID, cfg.AppleKeyID, cfg.ApplePrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Apple private key: %w", err)
+		}
+		clientSecret = generator
+	}
+
+	appleClient := apple.NewClient(apple.ClientConfig{
+		ClientID:     cfg.AppleClientID,
+		ClientSecret: clientSecret,
+		TokenURL:     cfg.AppleTokenURL,
+		RevokeURL:    cfg.AppleRevokeURL,
+	})
+
+	cipher, err := encryption.NewCipherFromBase64(cfg.AppleTokenEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize token encryption: %w", err)
+	}
+	appleTokenRepo := repository.NewAppleTokenRepository(dbPool, cipher)
+
+	return service.NewAppleTokenService(appleClient, appleTokenRepo, cfg.AppleRedirectURI), nil
+}
+
+// newIdentityProviders builds the generic OpenID Connect providers listed in OIDC_PROVIDERS
+func newIdentityProviders(cfg *config.Config) []service.IdentityProvider {
+	providers := make([]service.IdentityProvider, 0, len(cfg.OIDCProviders))
+	for _, providerCfg := range cfg.OIDCProviders {
+		verifier := oidc.NewVerifier(oidc.Config{
+			Issuer:   providerCfg.Issuer,
+			ClientID: providerCfg.ClientID,
+		})
+		providers = append(providers, service.NewOIDCProvider(providerCfg.Name, verifier))
+		log.Printf("OpenID Connect provider enabled: %s (%s)", providerCfg.Name, providerCfg.Issuer)
+	}
+	return providers
+}
+
+// linkableProviders returns every configured provider users can link to their account
+func linkableProviders(cfg *config.Config, appleVerifier *apple.Verifier, googleVerifier *google.Verifier, oidcProviders []service.IdentityProvider) []service.IdentityProvider {
+	var providers []service.IdentityProvider
+	if cfg.AppleClientID != "" {
+		providers = append(providers, service.NewAppleProvider(appleVerifier))
+	}
+	if cfg.GoogleClientID != "" {
+		providers = append(providers, service.NewGoogleProvider(googleVerifier))
+	}
+	return append(providers, oidcProviders...)
+}
+
+// routeHandlers groups the HTTP handlers mounted by setupRouter
+type routeHandlers struct {
+	auth     *handler.AuthHandler
+	session  *handler.SessionHandler
+	account  *handler.AccountHandler
+	profile  *handler.ProfileHandler
+	role     *handler.RoleHandler
+	admin    *handler.AdminHandler
+	identity *handler.IdentityHandler
+	webhook  *handler.WebhookHandler
+	jwks     *handler.JWKSHandler
+}
+
 // setupRouter configures all routes and middleware
-func setupRouter(authHandler *handler.AuthHandler, cfg *config.Config) *gin.Engine {
+func setupRouter(handlers *routeHandlers, authMiddleware *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter, cfg *config.Config) *gin.Engine {
 	// Set Gin mode based on environment
 	gin.SetMode(gin.ReleaseMode)
 
@@ -146,26 +417,71 @@ func setupRouter(authHandler *handler.AuthHandler, cfg *config.Config) *gin.Engi
 	router.Use(corsMiddleware(cfg.AllowedOrigins))
 
 	// Health check
-	router.GET("/health", authHandler.HealthCheck)
+	router.GET("/health", handlers.auth.HealthCheck)
 
-	// API routes with rate limiting
+	// Token verification keys for other services
+	router.GET("/.well-known/jwks.json", handlers.jwks.JWKS)
+
+	// API routes with rate limiting (policies in config.RateLimitPolicies)
 	api := router.Group("/api/v1")
 	{
-		// Rate limiter: 10 requests per minute per IP
-		rate := limiter.Rate{
-			Period: 1 * time.Minute,
-			Limit:  10,
-		}
-		store := memory.NewStore()
-		rateLimiter := limiter.New(store, rate)
-		rateLimitMiddleware := mgin.NewMiddleware(rateLimiter)
+		signInLimit := rateLimiter.Limit(config.RateLimitSignIn)
+		apiLimit := rateLimiter.Limit(config.RateLimitAPI)
 
 		auth := api.Group("/auth")
-		auth.Use(rateLimitMiddleware)
 		{
-			auth.POST("/apple", authHandler.SignInWithApple)
-			auth.POST("/google", authHandler.SignInWithGoogle)
-			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/apple", signInLimit, handlers.auth.SignInWithApple)
+			auth.POST("/google", signInLimit, handlers.auth.SignInWithGoogle)
+			auth.POST("/refresh", rateLimiter.Limit(config.RateLimitRefresh), handlers.auth.RefreshToken)
+			auth.POST("/:provider", signInLimit, handlers.auth.SignInWithProvider)
+			auth.POST("/logout", authMiddleware.RequireAuth(), apiLimit, handlers.session.Logout)
+			auth.POST("/logout-all", authMiddleware.RequireAuth(), apiLimit, handlers.session.LogoutAll)
+		}
+
+		sessions := api.Group("/sessions")
+		sessions.Use(authMiddleware.RequireAuth(), apiLimit)
+		{
+			sessions.GET("", handlers.session.ListSessions)
+			sessions.DELETE("/:id", handlers.session.RevokeSession)
+		}
+
+		me := api.Group("/me")
+		me.Use(authMiddleware.RequireAuth(), apiLimit)
+		{
+			me.GET("", handlers.profile.GetProfile)
+			me.PATCH("", handlers.profile.UpdateProfile)
+			me.DELETE("", handlers.account.DeleteAccount)
+			me.GET("/identities", handlers.identity.ListIdentities)
+			me.POST("/identities/:provider", handlers.identity.LinkIdentity)
+			me.DELETE("/identities/:provider", handlers.identity.UnlinkIdentity)
+		}
+
+		// Admin endpoints require the permission named on each route (see roles in the database)
+		admin := api.Group("/admin")
+		admin.Use(authMiddleware.RequireAuth(), apiLimit)
+		{
+			admin.GET("/permissions", middleware.RequirePermission(model.PermissionRolesRead), handlers.role.ListPermissions)
+			admin.GET("/roles", middleware.RequirePermission(model.PermissionRolesRead), handlers.role.ListRoles)
+			admin.POST("/roles", middleware.RequirePermission(model.PermissionRolesWrite), handlers.role.CreateRole)
+			admin.PUT("/roles/:role/permissions", middleware.RequirePermission(model.PermissionRolesWrite), handlers.role.SetRolePermissions)
+			admin.DELETE("/roles/:role", middleware.RequirePermission(model.PermissionRolesWrite), handlers.role.DeleteRole)
+			admin.GET("/users", middleware.RequirePermission(model.PermissionUsersRead), handlers.admin.SearchUsers)
+			admin.GET("/users/:id", middleware.RequirePermission(model.PermissionUsersRead), handlers.admin.GetUser)
+			admin.POST("/users/:id/suspend", middleware.RequirePermission(model.PermissionUsersWrite), handlers.admin.SuspendUser)
+			admin.POST("/users/:id/unsuspend", middleware.RequirePermission(model.PermissionUsersWrite), handlers.admin.UnsuspendUser)
+			admin.PUT("/users/:id/status", middleware.RequirePermission(model.PermissionUsersWrite), handlers.admin.SetUserStatus)
+			admin.POST("/users/:id/logout", middleware.RequirePermission(model.PermissionUsersWrite), handlers.admin.ForceLogout)
+			admin.GET("/users/:id/roles", middleware.RequirePermission(model.PermissionUsersRead), handlers.role.ListUserRoles)
+			admin.PUT("/users/:id/roles/:role", middleware.RequirePermission(model.PermissionRolesWrite), handlers.role.AssignRole)
+			admin.DELETE("/users/:id/roles/:role", middleware.RequirePermission(model.PermissionRolesWrite), handlers.role.UnassignRole)
+			admin.POST("/keys/rotate", middleware.RequirePermission(model.PermissionKeysRotate), handlers.admin.RotateSigningKey)
+		}
+
+		// Identity provider notifications are authenticated by their signed payloads
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("/apple", handlers.webhook.AppleNotification)
+			webhooks.POST("/google/risc", handlers.webhook.GoogleSecurityEvent)
 		}
 	}
 
@@ -198,7 +514,7 @@ func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		if allowed {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, X-Client-ID, X-Client-Secret, Authorization, accept, origin, Cache-Control, X-Requested-With")
 			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 		}
 
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler handles account-related HTTP requests for the current user
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+// NewAccountHandler creates a new account handler
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// DeleteAccount permanently deletes the current user's account
+// @Summary Delete account
+// @Description Delete the current user, end all sessions and revoke Sign in with Apple tokens
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /me [delete]
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	err := h.accountService.DeleteAccount(c.Request.Context(), claims)
+	if errors.Is(err, service.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Account deletion failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to delete account",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles user support and operations HTTP requests of the admin API
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// SearchUsers returns a page of users matching the query
+// @Summary Search users
+// @Description Search users by email prefix or provider user ID, ordered by ID (requires users:read)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param email query string false "Case-insensitive email prefix"
+// @Param provider query string false "Identity provider name"
+// @Param subject query string false "Provider user ID; requires provider"
+// @Param cursor query int false "next_cursor of the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} model.UserSearchResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Router /admin/users [get]
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	var req model.UserSearchRequest
+
+	// Bind and validate query
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.adminService.SearchUsers(c.Request.Context(), &req)
+	if err != nil {
+		h.adminError(c, err, "Failed to search users")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUser returns a user with their logins, sessions and roles
+// @Summary Get user
+// @Description Get a user with linked logins, active sessions and roles (requires users:read)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Success 200 {object} model.AdminUserResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.adminService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		h.adminError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SuspendUser suspends a user
+// @Summary Suspend user
+// @Description Block sign-in, token refresh and API access and end every session (requires users:write)
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Param request body model.SuspendUserRequest true "Suspension"
+// @Success 200 {object} model.User
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/suspend [post]
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req model.SuspendUserRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.SuspendUser(c.Request.Context(), claims, userID, &req)
+	if err != nil {
+		h.adminError(c, err, "Failed to suspend user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UnsuspendUser lifts a user's suspension
+// @Summary Unsuspend user
+// @Description Set the user's status to active (requires users:write)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Success 200 {object} model.User
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/unsuspend [post]
+func (h *AdminHandler) UnsuspendUser(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.UnsuspendUser(c.Request.Context(), claims, userID)
+	if err != nil {
+		h.adminError(c, err, "Failed to unsuspend user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// SetUserStatus sets a user's account status
+// @Summary Set user status
+// @Description Set the status to active, suspended, banned or pending_deletion, optionally expiring (requires users:write)
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Param request body model.SetUserStatusRequest true "Status"
+// @Success 200 {object} model.User
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/status [put]
+func (h *AdminHandler) SetUserStatus(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req model.SetUserStatusRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.SetUserStatus(c.Request.Context(), claims, userID, &req)
+	if err != nil {
+		h.adminError(c, err, "Failed to set user status")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ForceLogout ends every session of a user
+// @Summary Force logout
+// @Description Revoke every refresh and access token of a user (requires users:write)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/logout [post]
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.ForceLogout(c.Request.Context(), claims, userID); err != nil {
+		h.adminError(c, err, "Failed to log out user")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RotateSigningKey adds a new token signing key
+// @Summary Rotate signing key
+// @Description Generate a new signing key; it is published now and signs after JWT_KEY_PUBLISH_DELAY (requires keys:rotate)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 201 {object} model.SigningKey
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/keys/rotate [post]
+func (h *AdminHandler) RotateSigningKey(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	key, err := h.adminService.RotateSigningKey(c.Request.Context(), claims)
+	if err != nil {
+		h.adminError(c, err, "Failed to rotate signing key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// adminError writes the response for errors shared by the admin endpoints
+func (h *AdminHandler) adminError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSearch):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidStatus):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrSelfSuspension):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "You cannot restrict your own account",
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+		})
+	case errors.Is(err, service.ErrKeyRotationDisabled):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "key_rotation_disabled",
+			Message: "Signing key rotation requires JWT_KEY_ENCRYPTION_KEY",
+		})
+	default:
+		log.Printf("%s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: message,
+		})
+	}
+}
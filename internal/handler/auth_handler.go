@@ -2,16 +2,32 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
 	"github.com/Hamid207/ai-code-test1/internal/model"
 	"github.com/Hamid207/ai-code-test1/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	// maxUserAgentLength caps the stored User-Agent to keep session rows small
+	maxUserAgentLength = 512
+
+	// clientIDHeader names the client (e.g. "web", "ios") for per-client token lifetimes
+	clientIDHeader = "X-Client-ID"
+
+	// clientSecretHeader authenticates the client ID; without it the default lifetimes apply
+	clientSecretHeader = "X-Client-Secret"
+
+	// captchaTokenHeader carries a solved CAPTCHA, required after repeated failed sign-ins
+	captchaTokenHeader = "X-Captcha-Token"
+)
+
 // AuthHandler handles authentication-related HTTP requests
 type AuthHandler struct {
 	authService *service.AuthService
@@ -35,6 +51,9 @@ func NewAuthHandler(authService *service.AuthService, dbPool *pgxpool.Pool) *Aut
 // @Success 200 {object} model.AppleSignInResponse
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.LinkRequiredResponse
+// @Failure 429 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /auth/apple [post]
 func (h *AuthHandler) SignInWithApple(c *gin.Context) {
@@ -50,16 +69,9 @@ func (h *AuthHandler) SignInWithApple(c *gin.Context) {
 	}
 
 	// Process authentication
-	response, err := h.authService.SignInWithApple(c.Request.Context(), &req)
+	response, err := h.authService.SignInWithApple(c.Request.Context(), &req, clientInfo(c))
 	if err != nil {
-		// Log internal error for debugging (do not expose to client)
-		log.Printf("Authentication failed: %v", err)
-
-		// Return generic error message to prevent information disclosure
-		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
-			Error:   "authentication_failed",
-			Message: "Invalid or expired token",
-		})
+		signInError(c, "Apple", err)
 		return
 	}
 
@@ -75,6 +87,9 @@ func (h *AuthHandler) SignInWithApple(c *gin.Context) {
 // @Success 200 {object} model.GoogleSignInResponse
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.LinkRequiredResponse
+// @Failure 429 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /auth/google [post]
 func (h *AuthHandler) SignInWithGoogle(c *gin.Context) {
@@ -90,22 +105,129 @@ func (h *AuthHandler) SignInWithGoogle(c *gin.Context) {
 	}
 
 	// Process authentication
-	response, err := h.authService.SignInWithGoogle(c.Request.Context(), &req)
+	response, err := h.authService.SignInWithGoogle(c.Request.Context(), &req, clientInfo(c))
 	if err != nil {
-		// Log internal error for debugging (do not expose to client)
-		log.Printf("Google authentication failed: %v", err)
+		signInError(c, "Google", err)
+		return
+	}
 
-		// Return generic error message to prevent information disclosure
-		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
-			Error:   "authentication_failed",
-			Message: "Invalid or expired token",
+	c.JSON(http.StatusOK, response)
+}
+
+// SignInWithProvider handles sign-in with a generic OpenID Connect provider
+// @Summary Sign in with an OpenID Connect provider
+// @Description Authenticate user using an ID token of a provider configured in OIDC_PROVIDERS
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body model.ProviderSignInRequest true "Provider Sign In Request"
+// @Success 200 {object} model.ProviderSignInResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.LinkRequiredResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /auth/{provider} [post]
+func (h *AuthHandler) SignInWithProvider(c *gin.Context) {
+	var req model.ProviderSignInRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
 		})
 		return
 	}
 
+	provider := c.Param("provider")
+
+	// Process authentication
+	response, err := h.authService.SignInWithProvider(c.Request.Context(), provider, &req, clientInfo(c))
+	if errors.Is(err, service.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "unknown_provider",
+			Message: "Identity provider is not supported",
+		})
+		return
+	}
+	if err != nil {
+		signInError(c, provider, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
+// signInError writes the response for a failed sign-in
+func signInError(c *gin.Context, provider string, err error) {
+	// Log internal error for debugging (do not expose to client)
+	log.Printf("%s authentication failed: %v", provider, err)
+
+	var lockedOut *service.LockedOutError
+	if errors.As(err, &lockedOut) {
+		middleware.AbortLockedOut(c, lockedOut.RetryAfter)
+		return
+	}
+
+	if errors.Is(err, service.ErrCaptchaRequired) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Error:   "captcha_required",
+			Message: "Too many failed sign-ins; solve a CAPTCHA and send it in the " + captchaTokenHeader + " header",
+		})
+		return
+	}
+
+	var suspended *service.AccountSuspendedError
+	if errors.As(err, &suspended) {
+		middleware.AbortAccountSuspended(c, suspended.Status, suspended.ExpiresAt)
+		return
+	}
+
+	if errors.Is(err, service.ErrProviderDisabled) {
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Error:   "account_locked",
+			Message: "Sign-in with this account is disabled by the identity provider",
+		})
+		return
+	}
+
+	var linkRequired *service.LinkRequiredError
+	if errors.As(err, &linkRequired) {
+		c.JSON(http.StatusConflict, model.LinkRequiredResponse{
+			Error:      "link_required",
+			Message:    "An account with this email exists; sign in with one of its providers to link this login",
+			LinkTicket: linkRequired.Ticket,
+			ExpiresAt:  linkRequired.ExpiresAt,
+			Providers:  linkRequired.Providers,
+		})
+		return
+	}
+
+	if errors.Is(err, service.ErrInvalidLinkTicket) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_link_ticket",
+			Message: "Link ticket is invalid, expired or was issued for another account",
+		})
+		return
+	}
+
+	if errors.Is(err, service.ErrIdentityConflict) {
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "identity_conflict",
+			Message: "This login is linked to another account, or a login from this provider is already linked",
+		})
+		return
+	}
+
+	// Return generic error message to prevent information disclosure
+	c.JSON(http.StatusUnauthorized, model.ErrorResponse{
+		Error:   "authentication_failed",
+		Message: "Invalid or expired token",
+	})
+}
+
 // RefreshToken handles JWT token refresh
 // @Summary Refresh access token
 // @Description Generate a new access token using a valid refresh token
@@ -115,6 +237,8 @@ func (h *AuthHandler) SignInWithGoogle(c *gin.Context) {
 // @Success 200 {object} model.RefreshTokenResponse
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req model.RefreshTokenRequest
@@ -129,9 +253,19 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Refresh token
-	response, err := h.authService.RefreshAccessToken(c.Request.Context(), &req)
+	response, err := h.authService.RefreshAccessToken(c.Request.Context(), &req, clientInfo(c))
 	if err != nil {
 		log.Printf("Token refresh failed: %v", err)
+		var lockedOut *service.LockedOutError
+		if errors.As(err, &lockedOut) {
+			middleware.AbortLockedOut(c, lockedOut.RetryAfter)
+			return
+		}
+		var suspended *service.AccountSuspendedError
+		if errors.As(err, &suspended) {
+			middleware.AbortAccountSuspended(c, suspended.Status, suspended.ExpiresAt)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
 			Error:   "invalid_refresh_token",
 			Message: "Invalid or expired refresh token",
@@ -162,3 +296,19 @@ func (h *AuthHandler) HealthCheck(c *gin.Context) {
 		"database": "connected",
 	})
 }
+
+// clientInfo extracts the client details recorded with each session
+func clientInfo(c *gin.Context) model.ClientInfo {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return model.ClientInfo{
+		IPAddress:    c.ClientIP(),
+		UserAgent:    userAgent,
+		ClientID:     c.GetHeader(clientIDHeader),
+		ClientSecret: c.GetHeader(clientSecretHeader),
+		CaptchaToken: c.GetHeader(captchaTokenHeader),
+	}
+}
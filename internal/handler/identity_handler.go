@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// IdentityHandler handles the current user's linked provider logins
+type IdentityHandler struct {
+	identityService *service.IdentityService
+}
+
+// NewIdentityHandler creates a new identity handler
+func NewIdentityHandler(identityService *service.IdentityService) *IdentityHandler {
+	return &IdentityHandler{
+		identityService: identityService,
+	}
+}
+
+// ListIdentities returns the current user's linked logins
+// @Summary List linked logins
+// @Description List the identity providers the current user can sign in with
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} model.IdentityListResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /me/identities [get]
+func (h *IdentityHandler) ListIdentities(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	response, err := h.identityService.ListIdentities(c.Request.Context(), claims)
+	if err != nil {
+		log.Printf("Listing identities failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to list linked logins",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LinkIdentity links a provider login to the current user
+// @Summary Link login
+// @Description Connect an identity provider account by presenting a fresh ID token from it
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param provider path string true "Provider name"
+// @Param request body model.LinkIdentityRequest true "Link Identity Request"
+// @Success 200 {object} model.Identity
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /me/identities/{provider} [post]
+func (h *IdentityHandler) LinkIdentity(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	var req model.LinkIdentityRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	identity, err := h.identityService.LinkIdentity(c.Request.Context(), claims, c.Param("provider"), &req)
+	if errors.Is(err, service.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "unknown_provider",
+			Message: "Identity provider is not supported",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrIdentityConflict) {
+		log.Printf("Linking identity refused: %v", err)
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "identity_conflict",
+			Message: "This login is linked to another account, or a login from this provider is already linked",
+		})
+		return
+	}
+	if err != nil {
+		// Log internal error for debugging (do not expose to client)
+		log.Printf("Linking identity failed: %v", err)
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
+			Error:   "authentication_failed",
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
+
+// UnlinkIdentity removes a provider login from the current user
+// @Summary Unlink login
+// @Description Disconnect an identity provider account; the last login method cannot be removed
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param provider path string true "Provider name"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /me/identities/{provider} [delete]
+func (h *IdentityHandler) UnlinkIdentity(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	err := h.identityService.UnlinkIdentity(c.Request.Context(), claims, c.Param("provider"))
+	if errors.Is(err, service.ErrIdentityNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "identity_not_found",
+			Message: "No login from this provider is linked",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrLastIdentity) {
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "last_identity",
+			Message: "Cannot remove the only login method of the account",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Unlinking identity failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to unlink login",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
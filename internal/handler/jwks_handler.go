@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge is how long clients may cache the JWKS document, in seconds
+const jwksMaxAge = "max-age=3600"
+
+// JWKSHandler publishes the public keys that verify issued tokens
+type JWKSHandler struct {
+	tokenService *jwt.TokenService
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(tokenService *jwt.TokenService) *JWKSHandler {
+	return &JWKSHandler{
+		tokenService: tokenService,
+	}
+}
+
+// JWKS returns the token verification keys as a JSON Web Key Set
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens; empty while tokens are signed with HS256
+// @Produce json
+// @Success 200 {object} jwt.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, "+jwksMaxAge)
+	c.JSON(http.StatusOK, h.tokenService.JWKS())
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler handles profile-related HTTP requests for the current user
+type ProfileHandler struct {
+	profileService *service.ProfileService
+}
+
+// NewProfileHandler creates a new profile handler
+func NewProfileHandler(profileService *service.ProfileService) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+	}
+}
+
+// GetProfile returns the current user's profile
+// @Summary Get profile
+// @Description Get the current user's profile
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} model.User
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /me [get]
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	user, err := h.profileService.GetProfile(c.Request.Context(), claims)
+	if err != nil {
+		h.profileError(c, err, "Failed to get profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile edits the current user's profile
+// @Summary Update profile
+// @Description Set or clear the display name, avatar URL and locale; omitted fields are unchanged
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param request body model.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} model.User
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /me [patch]
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	var req model.UpdateProfileRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.profileService.UpdateProfile(c.Request.Context(), claims, &req)
+	if errors.Is(err, service.ErrInvalidProfile) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		h.profileError(c, err, "Failed to update profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// profileError writes the response for errors shared by the profile endpoints
+func (h *ProfileHandler) profileError(c *gin.Context, err error, message string) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	log.Printf("%s: %v", message, err)
+	c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+		Error:   "internal_server_error",
+		Message: message,
+	})
+}
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler handles role and permission administration HTTP requests
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+// ListPermissions returns every permission roles can grant
+// @Summary List permissions
+// @Description List the permissions checked by the API (requires roles:read)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} model.PermissionListResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /admin/permissions [get]
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	response, err := h.roleService.ListPermissions(c.Request.Context())
+	if err != nil {
+		h.roleError(c, err, "Failed to list permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListRoles returns every role with its permissions
+// @Summary List roles
+// @Description List roles and the permissions they grant (requires roles:read)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} model.RoleListResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /admin/roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	response, err := h.roleService.ListRoles(c.Request.Context())
+	if err != nil {
+		h.roleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateRole creates a role
+// @Summary Create role
+// @Description Create a role granting a set of permissions (requires roles:write and every permission granted)
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param request body model.CreateRoleRequest true "Role"
+// @Success 201 {object} model.Role
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	var req model.CreateRoleRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	role, err := h.roleService.CreateRole(c.Request.Context(), claims, &req)
+	if err != nil {
+		h.roleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// SetRolePermissions replaces the permissions a role grants
+// @Summary Set role permissions
+// @Description Replace a role's permissions; holders' access tokens are revoked (requires roles:write and every permission granted)
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param role path string true "Role name"
+// @Param request body model.SetRolePermissionsRequest true "Permissions"
+// @Success 200 {object} model.Role
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/roles/{role}/permissions [put]
+func (h *RoleHandler) SetRolePermissions(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	var req model.SetRolePermissionsRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	role, err := h.roleService.SetRolePermissions(c.Request.Context(), claims, c.Param("role"), &req)
+	if err != nil {
+		h.roleError(c, err, "Failed to set role permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role
+// @Summary Delete role
+// @Description Delete a role and unassign it from every user (requires roles:write)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param role path string true "Role name"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/roles/{role} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	err := h.roleService.DeleteRole(c.Request.Context(), claims, c.Param("role"))
+	if err != nil {
+		h.roleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserRoles returns the roles assigned to a user
+// @Summary List user roles
+// @Description List the roles assigned to a user (requires users:read)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Success 200 {object} model.RoleListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/roles [get]
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.roleService.ListUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		h.roleError(c, err, "Failed to list user roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AssignRole assigns a role to a user
+// @Summary Assign role
+// @Description Assign a role to a user; it is carried by the user's next access token (requires roles:write and every permission the role grants; the admin role requires admin)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} model.RoleListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /admin/users/{id}/roles/{role} [put]
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.roleService.AssignRole(c.Request.Context(), claims, userID, c.Param("role"))
+	if err != nil {
+		h.roleError(c, err, "Failed to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UnassignRole removes a role from a user
+// @Summary Unassign role
+// @Description Remove a role from a user and revoke the user's access tokens (requires roles:write; the admin role requires admin)
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Router /admin/users/{id}/roles/{role} [delete]
+func (h *RoleHandler) UnassignRole(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.roleService.UnassignRole(c.Request.Context(), claims, userID, c.Param("role"))
+	if err != nil {
+		h.roleError(c, err, "Failed to unassign role")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// roleError writes the response for errors shared by the role endpoints
+func (h *RoleHandler) roleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrUnknownPermission):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "unknown_permission",
+			Message: "Permission does not exist",
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+		})
+	case errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "role_not_found",
+			Message: "Role not found",
+		})
+	case errors.Is(err, service.ErrRoleNotAssigned):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "role_not_assigned",
+			Message: "User does not hold this role",
+		})
+	case errors.Is(err, service.ErrRoleExists):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "role_exists",
+			Message: "A role with this name already exists",
+		})
+	case errors.Is(err, service.ErrSystemRole):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "system_role",
+			Message: "System roles cannot be changed or deleted",
+		})
+	case errors.Is(err, service.ErrAdminRequired):
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Error:   "admin_required",
+			Message: "Only admins can assign or unassign the admin role",
+		})
+	case errors.Is(err, service.ErrPermissionNotHeld):
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Error:   "permission_not_held",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrLastAdmin):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "last_admin",
+			Message: "The last admin cannot be unassigned",
+		})
+	default:
+		log.Printf("%s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: message,
+		})
+	}
+}
+
+// userIDParam parses the :id path parameter, writing a 400 response if it is not a user ID
+func userIDParam(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid user ID",
+		})
+		return 0, false
+	}
+	return userID, true
+}
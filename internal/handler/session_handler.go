@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/middleware"
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler handles session-related HTTP requests
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// Logout handles sign-out from the current device
+// @Summary Log out
+// @Description Revoke the refresh token and blacklist the current access token
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param request body model.LogoutRequest true "Logout Request"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Router /auth/logout [post]
+func (h *SessionHandler) Logout(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	var req model.LogoutRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.sessionService.Logout(c.Request.Context(), claims, &req); err != nil {
+		log.Printf("Logout failed: %v", err)
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
+			Error:   "logout_failed",
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll handles sign-out from every device
+// @Summary Log out everywhere
+// @Description Revoke all refresh tokens and access tokens of the current user
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /auth/logout-all [post]
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	if err := h.sessionService.LogoutAll(c.Request.Context(), claims); err != nil {
+		log.Printf("Logout from all devices failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to log out from all devices",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListSessions returns the current user's active sessions
+// @Summary List sessions
+// @Description List devices the current user is signed in on
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} model.SessionListResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /sessions [get]
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	response, err := h.sessionService.ListSessions(c.Request.Context(), claims)
+	if err != nil {
+		log.Printf("Listing sessions failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to list sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession signs the current user out of one session
+// @Summary Revoke session
+// @Description Sign out of a single device
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	claims := middleware.MustGetClaims(c)
+
+	err := h.sessionService.RevokeSession(c.Request.Context(), claims, c.Param("id"))
+	if errors.Is(err, service.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "session_not_found",
+			Message: "Session not found",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Revoking session failed: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to revoke session",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler handles notifications pushed by identity providers
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// AppleNotification handles Sign in with Apple server-to-server notifications
+// @Summary Apple server-to-server notification
+// @Description Receive consent-revoked, account-delete, email-disabled and email-enabled events from Apple
+// @Accept json
+// @Produce json
+// @Param request body model.AppleNotificationRequest true "Signed notification"
+// @Success 200
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /webhooks/apple [post]
+func (h *WebhookHandler) AppleNotification(c *gin.Context) {
+	var req model.AppleNotificationRequest
+
+	// Bind and validate request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	err := h.webhookService.HandleAppleNotification(c.Request.Context(), req.Payload)
+	if errors.Is(err, service.ErrInvalidNotification) {
+		log.Printf("Rejected Apple notification: %v", err)
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
+			Error:   "invalid_notification",
+			Message: "Notification signature verification failed",
+		})
+		return
+	}
+	if err != nil {
+		// A non-2xx response makes Apple retry the notification later
+		log.Printf("Failed to process Apple notification: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to process notification",
+		})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GoogleSecurityEvent handles Google Cross-Account Protection (RISC) security event tokens
+// @Summary Google RISC security event
+// @Description Receive sessions-revoked, tokens-revoked, account-disabled and account-enabled events from Google
+// @Accept application/secevent+jwt
+// @Produce json
+// @Param request body string true "Security event token"
+// @Success 202
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /webhooks/google/risc [post]
+func (h *WebhookHandler) GoogleSecurityEvent(c *gin.Context) {
+	// The security event token is the raw request body (RFC 8935)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || len(body) == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Request body must contain a security event token",
+		})
+		return
+	}
+
+	err = h.webhookService.HandleGoogleSecurityEvent(c.Request.Context(), string(body))
+	if errors.Is(err, service.ErrInvalidNotification) {
+		log.Printf("Rejected Google security event: %v", err)
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse{
+			Error:   "invalid_notification",
+			Message: "Security event token verification failed",
+		})
+		return
+	}
+	if err != nil {
+		// A non-2xx response makes Google retry the event later
+		log.Printf("Failed to process Google security event: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Error:   "internal_server_error",
+			Message: "Failed to process security event",
+		})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// claimsContextKey is the gin context key holding the authenticated token claims
+	claimsContextKey = "auth.claims"
+)
+
+// UserSource loads the user an access token was issued to, for checking the account status
+type UserSource interface {
+	// GetUser returns the user, or nil if they do not exist
+	GetUser(ctx context.Context, userID int64) (*model.User, error)
+}
+
+// AuthMiddleware authenticates requests using Bearer access tokens
+type AuthMiddleware struct {
+	tokenService        *jwt.TokenService
+	blacklistRepository repository.RedisBlacklistRepository
+	users               UserSource
+}
+
+// NewAuthMiddleware creates a new authentication middleware
+func NewAuthMiddleware(tokenService *jwt.TokenService, blacklistRepo repository.RedisBlacklistRepository) *AuthMiddleware {
+	return &AuthMiddleware{
+		tokenService:        tokenService,
+		blacklistRepository: blacklistRepo,
+	}
+}
+
+// WithUserStatus rejects tokens of users whose account status blocks access
+// Suspending a user also revokes their tokens; this check covers tokens issued concurrently
+func (m *AuthMiddleware) WithUserStatus(users UserSource) *AuthMiddleware {
+	m.users = users
+	return m
+}
+
+// RequireAuth rejects requests without a valid, non-revoked access token
+// On success the token claims are stored in the gin context (see GetClaims)
+func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			abortUnauthorized(c, "Missing or malformed Authorization header")
+			return
+		}
+
+		claims, err := m.tokenService.ValidateToken(tokenString)
+		if err != nil {
+			log.Printf("Access token validation failed: %v", err)
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		// Refresh tokens are long-lived and must only be presented to /auth/refresh
+		if claims.TokenType != jwt.AccessToken {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		revoked, err := m.blacklistRepository.IsTokenRevoked(c.Request.Context(), claims.ID, claims.FamilyID, claims.UserID, claims.IssueTime())
+		if err != nil {
+			// Fail closed: without the blacklist we cannot tell whether the token was revoked
+			log.Printf("Access token revocation check failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, model.ErrorResponse{
+				Error:   "service_unavailable",
+				Message: "Unable to verify token",
+			})
+			return
+		}
+		if revoked {
+			abortUnauthorized(c, "Token has been revoked")
+			return
+		}
+
+		if m.users != nil && !m.checkUserStatus(c, claims) {
+			return
+		}
+
+		c.Set(claimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// checkUserStatus aborts the request unless the token's user exists and is active
+func (m *AuthMiddleware) checkUserStatus(c *gin.Context, claims *jwt.TokenClaims) bool {
+	user, err := m.users.GetUser(c.Request.Context(), claims.UserID)
+	if err != nil {
+		// Fail closed, like the revocation check
+		log.Printf("Account status check failed: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, model.ErrorResponse{
+			Error:   "service_unavailable",
+			Message: "Unable to verify token",
+		})
+		return false
+	}
+	if user == nil {
+		abortUnauthorized(c, "Invalid or expired token")
+		return false
+	}
+
+	if status := user.EffectiveStatus(time.Now()); status != model.UserStatusActive {
+		AbortAccountSuspended(c, status, user.StatusExpiresAt)
+		return false
+	}
+
+	return true
+}
+
+// AbortAccountSuspended stops the request with a 403 for a user whose status blocks access
+func AbortAccountSuspended(c *gin.Context, status model.UserStatus, expiresAt *time.Time) {
+	message := "This account has been suspended"
+	switch status {
+	case model.UserStatusBanned:
+		message = "This account has been banned"
+	case model.UserStatusPendingDeletion:
+		message = "This account is scheduled for deletion"
+	}
+
+	c.AbortWithStatusJSON(http.StatusForbidden, model.AccountSuspendedResponse{
+		Error:     "account_suspended",
+		Message:   message,
+		Status:    status,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// RequirePermission rejects requests whose access token does not grant a permission
+// Permissions are carried in the token's scope claim; mount after RequireAuth
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortUnauthorized(c, "Missing or malformed Authorization header")
+			return
+		}
+
+		if !claims.HasScope(permission) {
+			log.Printf("User %d lacks permission %s for %s %s", claims.UserID, permission, c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Missing permission " + permission,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetClaims returns the claims stored by RequireAuth
+func GetClaims(c *gin.Context) (*jwt.TokenClaims, bool) {
+	value, exists := c.Get(claimsContextKey)
+	if !exists {
+		return nil, false
+	}
+
+	claims, ok := value.(*jwt.TokenClaims)
+	return claims, ok
+}
+
+// MustGetClaims returns the claims stored by RequireAuth
+// Panics if called on a route that is not protected by RequireAuth (programming error)
+func MustGetClaims(c *gin.Context) *jwt.TokenClaims {
+	claims, ok := GetClaims(c)
+	if !ok {
+		panic("middleware: MustGetClaims called without RequireAuth")
+	}
+	return claims
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// abortUnauthorized stops the request with a 401 and a Bearer challenge
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+		Error:   "unauthorized",
+		Message: message,
+	})
+}
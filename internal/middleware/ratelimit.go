@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+const (
+	// rateLimitTimeout bounds Redis calls so an unresponsive Redis does not hold up requests
+	rateLimitTimeout = 100 * time.Millisecond
+
+	// rateLimitRetryInterval is how long requests are counted locally after a Redis failure
+	rateLimitRetryInterval = 5 * time.Second
+)
+
+// RatePolicy limits the requests each client can send to the routes using it
+type RatePolicy struct {
+	Name      string
+	Algorithm model.RateLimitAlgorithm // Used with Redis; local counters always use fixed windows
+	Limit     int64                    // 0 disables the policy
+	Period    time.Duration
+	PerUser   bool // Count per authenticated user instead of per IP address
+}
+
+// RateLimiter enforces rate limit policies with counters shared by all instances through Redis
+// While Redis is unavailable each instance counts requests locally, so limits then apply per instance
+type RateLimiter struct {
+	repository repository.RedisRateLimitRepository
+	policies   map[string]RatePolicy
+	local      map[string]*limiter.Limiter
+
+	// redisRetryAt holds the time (Unix nanoseconds) before which Redis is not tried again
+	redisRetryAt atomic.Int64
+}
+
+// NewRateLimiter creates a rate limiter enforcing the given policies
+func NewRateLimiter(rateLimitRepo repository.RedisRateLimitRepository, policies ...RatePolicy) *RateLimiter {
+	l := &RateLimiter{
+		repository: rateLimitRepo,
+		policies:   make(map[string]RatePolicy, len(policies)),
+		local:      make(map[string]*limiter.Limiter, len(policies)),
+	}
+	for _, policy := range policies {
+		l.policies[policy.Name] = policy
+		l.local[policy.Name] = limiter.New(memory.NewStore(), limiter.Rate{
+			Period: policy.Period,
+			Limit:  policy.Limit,
+		})
+	}
+	return l
+}
+
+// Limit returns a middleware enforcing the named policy
+// Per-user policies must run after RequireAuth; requests without claims are counted per IP address
+// Panics if the policy does not exist, as routes are set up at startup
+func (l *RateLimiter) Limit(name string) gin.HandlerFunc {
+	policy, ok := l.policies[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit policy %q", name))
+	}
+	if policy.Limit == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		result, ok := l.count(c, policy)
+		if !ok {
+			// Fail open: rate limiting must not take the API down
+			c.Next()
+			return
+		}
+
+		// Headers as in the IETF RateLimit header fields draft, in whole seconds rounded up
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+		c.Header("RateLimit-Limit", strconv.FormatInt(policy.Limit, 10))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.FormatInt(max(ceilSeconds(result.RetryAfter), 1), 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.ErrorResponse{
+				Error:   "rate_limit_exceeded",
+				Message: "Too many requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AbortLockedOut stops the request with a 429 while repeated failed attempts lock the client out
+func AbortLockedOut(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.FormatInt(max(ceilSeconds(retryAfter), 1), 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, model.ErrorResponse{
+		Error:   "too_many_attempts",
+		Message: "Too many failed attempts, try again later",
+	})
+}
+
+// count records a request under the policy, in Redis if it is available and locally otherwise
+// Returns false if the request could not be counted at all
+func (l *RateLimiter) count(c *gin.Context, policy RatePolicy) (*model.RateLimitResult, bool) {
+	var userID int64
+	if policy.PerUser {
+		if claims, ok := GetClaims(c); ok {
+			userID = claims.UserID
+		}
+	}
+
+	if time.Now().UnixNano() >= l.redisRetryAt.Load() {
+		result, err := l.countInRedis(c.Request.Context(), policy, userID, c.ClientIP())
+		if err == nil {
+			return result, true
+		}
+
+		// Skip Redis for a while instead of waiting for it on every request
+		if l.redisRetryAt.Swap(time.Now().Add(rateLimitRetryInterval).UnixNano()) <= time.Now().UnixNano() {
+			log.Printf("Rate limiting falls back to local counters: %v", err)
+		}
+	}
+
+	result, err := l.countLocally(c.Request.Context(), policy, userID, c.ClientIP())
+	if err != nil {
+		log.Printf("Local rate limiting failed: %v", err)
+		return nil, false
+	}
+	return result, true
+}
+
+// countInRedis counts the request against the client's limit shared through Redis
+func (l *RateLimiter) countInRedis(ctx context.Context, policy RatePolicy, userID int64, ipAddress string) (*model.RateLimitResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, rateLimitTimeout)
+	defer cancel()
+
+	limit := model.RateLimit{
+		Policy:    policy.Name,
+		Algorithm: policy.Algorithm,
+		Limit:     policy.Limit,
+		Period:    policy.Period,
+	}
+	if userID != 0 {
+		return l.repository.AllowUserRequest(ctx, limit, userID)
+	}
+	return l.repository.AllowIPRequest(ctx, limit, ipAddress)
+}
+
+// countLocally increments the client's counter held by this instance
+func (l *RateLimiter) countLocally(ctx context.Context, policy RatePolicy, userID int64, ipAddress string) (*model.RateLimitResult, error) {
+	key := "ip:" + ipAddress
+	if userID != 0 {
+		key = "user:" + strconv.FormatInt(userID, 10)
+	}
+
+	limit, err := l.local[policy.Name].Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	resetAfter := max(time.Until(time.Unix(limit.Reset, 0)), 0)
+	result := &model.RateLimitResult{
+		Allowed:    !limit.Reached,
+		Remaining:  limit.Remaining,
+		ResetAfter: resetAfter,
+	}
+	if limit.Reached {
+		result.RetryAfter = resetAfter
+	}
+	return result, nil
+}
+
+// ceilSeconds returns a duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(max(d, 0).Seconds()))
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeRateLimitRepository answers every request with result, or fails with err
+type fakeRateLimitRepository struct {
+	result *model.RateLimitResult
+	err    error
+	calls  int
+}
+
+func (r *fakeRateLimitRepository) AllowUserRequest(ctx context.Context, limit model.RateLimit, userID int64) (*model.RateLimitResult, error) {
+	r.calls++
+	return r.result, r.err
+}
+
+func (r *fakeRateLimitRepository) AllowIPRequest(ctx context.Context, limit model.RateLimit, ipAddress string) (*model.RateLimitResult, error) {
+	r.calls++
+	return r.result, r.err
+}
+
+// newRateLimitedRouter serves GET / behind the "test" policy, authenticating requests
+// that carry an X-User-ID header as that user
+func newRateLimitedRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		if c.GetHeader("X-User-ID") != "" {
+			c.Set(claimsContextKey, &jwt.TokenClaims{UserID: 42})
+		}
+	}, limiter.Limit("test"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// request sends GET / from ipAddress and returns the response
+func request(router *gin.Engine, ipAddress string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ipAddress + ":12345"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRateLimiter_UsesRedisWhileAvailable(t *testing.T) {
+	tests := []struct {
+		name          string
+		result        *model.RateLimitResult
+		wantStatus    int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{
+			name:          "allowed",
+			result:        &model.RateLimitResult{Allowed: true, Remaining: 4, ResetAfter: 1500 * time.Millisecond},
+			wantStatus:    http.StatusOK,
+			wantRemaining: "4",
+		},
+		{
+			name:          "denied",
+			result:        &model.RateLimitResult{ResetAfter: 30 * time.Second, RetryAfter: 200 * time.Millisecond},
+			wantStatus:    http.StatusTooManyRequests,
+			wantRemaining: "0",
+			wantRetry:     "1", // Rounded up to whole seconds
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRateLimitRepository{result: tt.result}
+			limiter := NewRateLimiter(repo, RatePolicy{Name: "test", Limit: 5, Period: time.Minute})
+			router := newRateLimitedRouter(limiter)
+
+			recorder := request(router, "192.0.2.1", nil)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+			if repo.calls != 1 {
+				t.Errorf("Redis called %d times, want 1", repo.calls)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_FallsBackToLocalCounters(t *testing.T) {
+	tests := []struct {
+		name    string
+		perUser bool
+		header  http.Header
+	}{
+		{name: "per IP address"},
+		{name: "per user", perUser: true, header: http.Header{"X-User-Id": {"42"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRateLimitRepository{err: errors.New("connection refused")}
+			limiter := NewRateLimiter(repo, RatePolicy{Name: "test", Limit: 2, Period: time.Minute, PerUser: tt.perUser})
+			router := newRateLimitedRouter(limiter)
+
+			wantStatus := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+			for i, want := range wantStatus {
+				recorder := request(router, "192.0.2.1", tt.header)
+				if recorder.Code != want {
+					t.Errorf("request %d: status = %d, want %d", i+1, recorder.Code, want)
+				}
+				if want == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After", i+1)
+				}
+			}
+
+			// Redis is not waited for on every request while it is down
+			if repo.calls != 1 {
+				t.Errorf("Redis called %d times, want 1", repo.calls)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_LocalCountersArePerClient(t *testing.T) {
+	repo := &fakeRateLimitRepository{err: errors.New("connection refused")}
+	limiter := NewRateLimiter(repo, RatePolicy{Name: "test", Limit: 1, Period: time.Minute})
+	router := newRateLimitedRouter(limiter)
+
+	if recorder := request(router, "192.0.2.1", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("first client: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if recorder := request(router, "192.0.2.1", nil); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("first client over the limit: status = %d, want %d", recorder.Code, http.StatusTooManyRequests)
+	}
+	if recorder := request(router, "192.0.2.2", nil); recorder.Code != http.StatusOK {
+		t.Errorf("second client: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimiter_ReturnsToRedisAfterRetryInterval(t *testing.T) {
+	repo := &fakeRateLimitRepository{err: errors.New("connection refused")}
+	limiter := NewRateLimiter(repo, RatePolicy{Name: "test", Limit: 5, Period: time.Minute})
+	router := newRateLimitedRouter(limiter)
+
+	request(router, "192.0.2.1", nil)
+
+	// Redis recovers; it is tried again once the retry interval has passed
+	repo.err = nil
+	repo.result = &model.RateLimitResult{Allowed: true, Remaining: 3, ResetAfter: time.Minute}
+	request(router, "192.0.2.1", nil)
+	if repo.calls != 1 {
+		t.Fatalf("Redis called %d times within the retry interval, want 1", repo.calls)
+	}
+
+	limiter.redisRetryAt.Store(time.Now().Add(-time.Second).UnixNano())
+	recorder := request(router, "192.0.2.1", nil)
+	if repo.calls != 2 {
+		t.Errorf("Redis called %d times after the retry interval, want 2", repo.calls)
+	}
+	if got := recorder.Header().Get("RateLimit-Remaining"); got != "3" {
+		t.Errorf("RateLimit-Remaining = %q, want the Redis count 3", got)
+	}
+}
+
+func TestRateLimiter_DisabledPolicy(t *testing.T) {
+	repo := &fakeRateLimitRepository{err: errors.New("not called")}
+	limiter := NewRateLimiter(repo, RatePolicy{Name: "test", Limit: 0, Period: time.Minute})
+	router := newRateLimitedRouter(limiter)
+
+	for i := 0; i < 3; i++ {
+		if recorder := request(router, "192.0.2.1", nil); recorder.Code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want %d", i+1, recorder.Code, http.StatusOK)
+		}
+	}
+	if repo.calls != 0 {
+		t.Errorf("Redis called %d times for a disabled policy", repo.calls)
+	}
+}
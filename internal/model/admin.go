@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// UserSearchRequest represents the query of the admin user search
+// Filters are combined; results are ordered by user ID and paginated with Cursor
+type UserSearchRequest struct {
+	Email    string `form:"email"`    // Case-insensitive email prefix
+	Provider string `form:"provider"` // Users with a login at this provider
+	Subject  string `form:"subject"`  // Provider user ID (ID token sub); requires Provider
+	Cursor   int64  `form:"cursor" binding:"omitempty,min=1"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// UserSearchResponse represents a page of admin user search results
+type UserSearchResponse struct {
+	Users      []User `json:"users"`
+	NextCursor int64  `json:"next_cursor,omitempty"` // Omitted on the last page
+}
+
+// AdminUserResponse represents a user with their logins, sessions and roles
+type AdminUserResponse struct {
+	User       *User      `json:"user"`
+	Identities []Identity `json:"identities"`
+	Sessions   []Session  `json:"sessions"`
+	Roles      []Role     `json:"roles"`
+}
+
+// SuspendUserRequest represents the request body for suspending a user
+type SuspendUserRequest struct {
+	Reason    string     `json:"reason" binding:"required,max=500"`
+	ExpiresAt *time.Time `json:"expires_at"` // Optional end of the suspension
+}
+
+// SetUserStatusRequest represents the request body for setting a user's account status
+type SetUserStatusRequest struct {
+	Status    UserStatus `json:"status" binding:"required,oneof=active suspended banned pending_deletion"`
+	Reason    string     `json:"reason" binding:"max=500"`
+	ExpiresAt *time.Time `json:"expires_at"` // Optional; when a non-active status reverts to active
+}
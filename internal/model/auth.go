@@ -6,6 +6,22 @@ import "time"
 type AppleSignInRequest struct {
 	IDToken string `json:"id_token" binding:"required"`
 	Nonce   string `json:"nonce" binding:"required"`
+	// AuthorizationCode is redeemed server-side for an Apple refresh token (optional)
+	AuthorizationCode string `json:"authorization_code,omitempty"`
+	// LinkTicket confirms a pending link_required ticket (optional)
+	LinkTicket string `json:"link_ticket,omitempty"`
+	// User is the user object Apple returns to the app on the first sign-in only (optional)
+	User *AppleUser `json:"user,omitempty"`
+}
+
+// AppleUser is the user object of Apple's first sign-in response
+// The name is not part of the ID token and is never sent again, so it is stored on first use
+type AppleUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Email string `json:"email,omitempty"` // Ignored: the ID token's email is authoritative
 }
 
 // AppleSignInResponse represents the response after successful authentication
@@ -36,7 +52,8 @@ type RefreshTokenResponse struct {
 
 // GoogleSignInRequest represents the request body for Google sign-in
 type GoogleSignInRequest struct {
-	IDToken string `json:"id_token" binding:"required"`
+	IDToken    string `json:"id_token" binding:"required"`
+	LinkTicket string `json:"link_ticket,omitempty"` // Confirms a pending link_required ticket
 }
 
 // GoogleSignInResponse represents the response after successful Google authentication
@@ -51,8 +68,42 @@ type GoogleSignInResponse struct {
 	TokenType             string    `json:"token_type"` // Always "Bearer"
 }
 
+// ProviderSignInRequest represents the request body for sign-in with a generic OpenID Connect provider
+type ProviderSignInRequest struct {
+	IDToken    string `json:"id_token" binding:"required"`
+	Nonce      string `json:"nonce,omitempty"`       // Required if the ID token was requested with a nonce
+	LinkTicket string `json:"link_ticket,omitempty"` // Confirms a pending link_required ticket
+}
+
+// ProviderSignInResponse represents the response after successful sign-in with a generic provider
+type ProviderSignInResponse struct {
+	UserID                int64     `json:"user_id"`
+	Provider              string    `json:"provider"`
+	Subject               string    `json:"subject"`
+	Email                 string    `json:"email"`
+	AccessToken           string    `json:"access_token"`
+	RefreshToken          string    `json:"refresh_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	TokenType             string    `json:"token_type"` // Always "Bearer"
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 }
+
+// AccountSuspendedResponse is returned when a user whose status blocks access signs in,
+// refreshes tokens or calls the API
+type AccountSuspendedResponse struct {
+	Error     string     `json:"error"` // Always "account_suspended"
+	Message   string     `json:"message,omitempty"`
+	Status    UserStatus `json:"status"`               // suspended, banned or pending_deletion
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // When access is restored, if it will be
+}
+
+// LogoutRequest represents the request body for logout
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
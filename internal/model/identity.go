@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// Identity provider names with dedicated sign-in endpoints
+// Generic OpenID Connect providers use the names configured in OIDC_PROVIDERS
+const (
+	ProviderApple  = "apple"
+	ProviderGoogle = "google"
+)
+
+// Identity represents a login at an external identity provider linked to a user
+type Identity struct {
+	ID            int64      `json:"-" db:"id"`
+	UserID        int64      `json:"-" db:"user_id"`
+	Provider      string     `json:"provider" db:"provider"`
+	Subject       string     `json:"subject" db:"subject"`
+	Email         string     `json:"email,omitempty" db:"email"`
+	EmailVerified bool       `json:"email_verified" db:"email_verified"`
+	LinkedAt      time.Time  `json:"linked_at" db:"linked_at"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+
+	// DisabledAt is set while the provider reports the account as disabled
+	DisabledAt *time.Time `json:"-" db:"disabled_at"`
+
+	// Profile is asserted by the provider at sign-in; it is stored on the user, not the identity
+	Profile Profile `json:"-" db:"-"`
+}
+
+// LinkIdentityRequest represents the request body for linking a provider login to the current user
+type LinkIdentityRequest struct {
+	IDToken string `json:"id_token" binding:"required"`
+	Nonce   string `json:"nonce,omitempty"` // Required for Apple
+
+	// AuthorizationCode is Apple's one-time code; redeemed so the link can be revoked later
+	AuthorizationCode string `json:"authorization_code,omitempty"`
+}
+
+// IdentityListResponse represents the identities linked to the current user
+type IdentityListResponse struct {
+	Identities []Identity `json:"identities"`
+}
+
+// LinkTicket is a pending request to link a new identity to an existing user
+// It is confirmed by signing in to the existing user with a login it already has
+type LinkTicket struct {
+	UserID   int64     `json:"user_id"`  // User owning the identity's email
+	Identity Identity  `json:"identity"` // Identity to link once confirmed
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// LinkRequiredResponse is returned when a sign-in matches an existing user by email
+// but the linking policy does not allow linking automatically. The client signs in with
+// one of Providers and sends LinkTicket along to link the new login.
+type LinkRequiredResponse struct {
+	Error      string    `json:"error"` // Always "link_required"
+	Message    string    `json:"message,omitempty"`
+	LinkTicket string    `json:"link_ticket"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Providers  []string  `json:"providers"` // Providers the existing user can sign in with
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// RateLimitAlgorithm selects how a rate limit counts requests
+type RateLimitAlgorithm string
+
+const (
+	// RateLimitFixedWindow counts requests in windows starting at the first request
+	// Cheapest, but a client can send twice the limit across a window boundary
+	RateLimitFixedWindow RateLimitAlgorithm = "fixed_window"
+
+	// RateLimitSlidingWindow keeps a log of the request times within the last period
+	// Exact, at the cost of one entry per request allowed in the period
+	RateLimitSlidingWindow RateLimitAlgorithm = "sliding_window"
+
+	// RateLimitGCRA is the generic cell rate algorithm, a token bucket holding one timestamp
+	// Allows bursts of the limit, then spaces requests evenly over the period
+	RateLimitGCRA RateLimitAlgorithm = "gcra"
+)
+
+// RateLimit allows Limit requests per Period under a named policy
+type RateLimit struct {
+	Policy    string
+	Algorithm RateLimitAlgorithm
+	Limit     int64
+	Period    time.Duration
+}
+
+// RateLimitResult is the outcome of counting a request against a rate limit
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int64         // Requests still allowed right now
+	ResetAfter time.Duration // Time until the full limit is available again
+	RetryAfter time.Duration // Time until the next request is allowed, if this one was not
+}
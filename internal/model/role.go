@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// Permissions checked by the API (see migrations/014_create_rbac_tables.sql)
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+	PermissionRolesRead  = "roles:read"
+	PermissionRolesWrite = "roles:write"
+	PermissionKeysRotate = "keys:rotate"
+)
+
+// RoleAdmin is the system role holding every permission
+const RoleAdmin = "admin"
+
+// Permission represents a permission that roles can grant
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+// Role represents a named set of permissions assigned to users
+type Role struct {
+	ID          int64     `json:"-" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions"`
+	System      bool      `json:"system" db:"system"` // Managed by migrations; read-only through the API
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Authorization holds the roles of a user and the permissions they grant
+type Authorization struct {
+	Roles       []string
+	Permissions []string
+}
+
+// CreateRoleRequest represents the request body for creating a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// SetRolePermissionsRequest represents the request body for replacing a role's permissions
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// RoleListResponse represents a list of roles
+type RoleListResponse struct {
+	Roles []Role `json:"roles"`
+}
+
+// PermissionListResponse represents the permissions roles can grant
+type PermissionListResponse struct {
+	Permissions []Permission `json:"permissions"`
+}
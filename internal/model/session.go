@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ClientInfo describes the client a request originates from
+// Recorded with each refresh token so users can recognise their devices
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	ClientID  string // X-Client-ID header, selects per-client token lifetimes
+
+	// X-Client-Secret header, authenticates ClientID (not recorded)
+	ClientSecret string
+
+	// X-Captcha-Token header, required at sign-in after repeated failures (not recorded)
+	CaptchaToken string
+}
+
+// Session represents an active sign-in (one refresh token family) on a device
+type Session struct {
+	ID         string    `json:"id"` // Token family ID
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionListResponse represents the response for listing active sessions
+type SessionListResponse struct {
+	Sessions []Session `json:"sessions"`
+}
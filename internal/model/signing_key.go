@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SigningKey represents a token signing key of the key ring in the database
+type SigningKey struct {
+	ID            string     `json:"kid" db:"kid"`
+	Algorithm     string     `json:"alg" db:"algorithm"`
+	PrivateKeyPEM []byte     `json:"-" db:"encrypted_private_key"` // Decrypted; never expose in JSON
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ActivatesAt   time.Time  `json:"activates_at" db:"activates_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
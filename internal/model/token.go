@@ -2,13 +2,26 @@ package model
 
 import "time"
 
+// Reasons a refresh token was revoked
+const (
+	RefreshTokenRotated = "rotated" // Exchanged for a new token; presenting it again is reuse
+	RefreshTokenLogout  = "logout"  // Its session ended: logout, session revocation, suspension or detected reuse
+)
+
 // RefreshToken represents a refresh token in the database
 type RefreshToken struct {
 	ID         int64      `json:"id" db:"id"`
 	UserID     int64      `json:"user_id" db:"user_id"`
 	TokenHash  string     `json:"-" db:"token_hash"` // Never expose in JSON
+	TokenID    string     `json:"token_id,omitempty" db:"token_id"`
+	FamilyID   string     `json:"token_family" db:"token_family"`
+	IPAddress  string     `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent  string     `json:"user_agent,omitempty" db:"user_agent"`
 	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+
+	// RevokedReason is RefreshTokenRotated or RefreshTokenLogout; empty while active
+	RevokedReason string `json:"revoked_reason,omitempty" db:"revoked_reason"`
 }
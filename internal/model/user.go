@@ -5,9 +5,63 @@ import "time"
 // User represents a user in the database
 type User struct {
 	ID        int64     `json:"id" db:"id"`
-	AppleID   string    `json:"apple_id,omitempty" db:"apple_id"`
-	GoogleID  string    `json:"google_id,omitempty" db:"google_id"`
 	Email     string    `json:"email" db:"email"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// EmailDeliverable is false while an Apple private relay address has forwarding disabled
+	EmailDeliverable bool `json:"email_deliverable" db:"email_deliverable"`
+
+	// Profile fields, empty if unknown
+	DisplayName string `json:"display_name,omitempty" db:"display_name"`
+	AvatarURL   string `json:"avatar_url,omitempty" db:"avatar_url"`
+	Locale      string `json:"locale,omitempty" db:"locale"`
+
+	// Status is the account status; every status other than active blocks access
+	Status          UserStatus `json:"status" db:"status"`
+	StatusReason    string     `json:"status_reason,omitempty" db:"status_reason"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty" db:"status_expires_at"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty" db:"status_changed_at"`
+}
+
+// UserStatus is the status of a user account
+type UserStatus string
+
+const (
+	UserStatusActive          UserStatus = "active"
+	UserStatusSuspended       UserStatus = "suspended"        // Temporarily blocked, e.g. during an investigation
+	UserStatusBanned          UserStatus = "banned"           // Permanently blocked for abuse
+	UserStatusPendingDeletion UserStatus = "pending_deletion" // Blocked until the account is deleted
+)
+
+// EffectiveStatus returns the user's status at a point in time
+// A status whose expiry has passed has reverted to active
+func (u *User) EffectiveStatus(now time.Time) UserStatus {
+	if u.StatusExpiresAt != nil && !now.Before(*u.StatusExpiresAt) {
+		return UserStatusActive
+	}
+	if u.Status == "" {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
+// Profile holds the profile fields asserted by an identity provider at sign-in
+type Profile struct {
+	DisplayName string
+	AvatarURL   string
+	Locale      string
+}
+
+// IsEmpty reports whether the provider asserted no profile fields
+func (p Profile) IsEmpty() bool {
+	return p.DisplayName == "" && p.AvatarURL == "" && p.Locale == ""
+}
+
+// UpdateProfileRequest represents the request body for editing the current user's profile
+// Omitted fields are left unchanged; an empty string clears a field
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Locale      *string `json:"locale"`
 }
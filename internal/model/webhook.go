@@ -0,0 +1,6 @@
+package model
+
+// AppleNotificationRequest represents a Sign in with Apple server-to-server notification
+type AppleNotificationRequest struct {
+	Payload string `json:"payload" binding:"required"` // Signed JWT containing the event
+}
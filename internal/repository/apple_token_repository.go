@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/pkg/encryption"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// appleValidationLockID is the advisory lock letting one instance at a time validate Apple tokens
+const appleValidationLockID = 0x6170706c65746f6b // "appletok"
+
+// AppleTokenRepository handles database operations for Apple refresh tokens
+// Tokens are encrypted before they are written and decrypted after they are read
+type AppleTokenRepository struct {
+	db     *pgxpool.Pool
+	cipher *encryption.Cipher
+}
+
+// NewAppleTokenRepository creates a new Apple token repository
+func NewAppleTokenRepository(db *pgxpool.Pool, cipher *encryption.Cipher) *AppleTokenRepository {
+	return &AppleTokenRepository{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// StoreRefreshToken stores (or replaces) the user's Apple refresh token
+func (r *AppleTokenRepository) StoreRefreshToken(ctx context.Context, userID int64, refreshToken string) error {
+	encrypted, err := r.cipher.Encrypt(refreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt apple refresh token: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO apple_tokens (user_id, encrypted_refresh_token, last_validated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			encrypted_refresh_token = EXCLUDED.encrypted_refresh_token,
+			last_validated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = r.db.Exec(ctx, query, userID, encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to store apple refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetRefreshToken returns the user's Apple refresh token, or "" if none is stored
+func (r *AppleTokenRepository) GetRefreshToken(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT encrypted_refresh_token
+		FROM apple_tokens
+		WHERE user_id = $1
+	`
+
+	var encrypted string
+	err := r.db.QueryRow(ctx, query, userID).Scan(&encrypted)
+	if err == pgx.ErrNoRows {
+		return "", nil // No token stored
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get apple refresh token: %w", err)
+	}
+
+	refreshToken, err := r.cipher.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt apple refresh token: %w", err)
+	}
+
+	return refreshToken, nil
+}
+
+// MarkValidated records a successful validation of the user's Apple refresh token
+func (r *AppleTokenRepository) MarkValidated(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE apple_tokens
+		SET last_validated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+	`
+
+	_, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark apple refresh token validated: %w", err)
+	}
+
+	return nil
+}
+
+// ListUnvalidatedSince returns up to limit users, ordered by ID and after afterUserID,
+// whose Apple refresh token was last validated before validatedBefore
+func (r *AppleTokenRepository) ListUnvalidatedSince(ctx context.Context, validatedBefore time.Time, afterUserID int64, limit int) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT user_id
+		FROM apple_tokens
+		WHERE (last_validated_at IS NULL OR last_validated_at < $1)
+		  AND user_id > $2
+		ORDER BY user_id
+		LIMIT $3
+	`
+
+	// last_validated_at is a TIMESTAMP column, so pass UTC: pgx drops the offset of other zones
+	rows, err := r.db.Query(ctx, query, validatedBefore.UTC(), afterUserID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apple tokens to validate: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan apple token: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list apple tokens to validate: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// WithValidationLock runs fn while holding the Apple token validation advisory lock
+// Returns false without running fn if another instance holds the lock
+func (r *AppleTokenRepository) WithValidationLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	// Session-level lock: fn calls Apple, so it must not run inside a transaction
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, appleValidationLockID).Scan(&locked); err != nil {
+		return false, fmt.Errorf("failed to lock apple token validation: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultQueryTimeout)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, appleValidationLockID); err != nil {
+			// Closing the connection releases the lock with the session
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	return true, fn(ctx)
+}
+
+// DeleteRefreshToken removes the user's Apple refresh token
+func (r *AppleTokenRepository) DeleteRefreshToken(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `DELETE FROM apple_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete apple refresh token: %w", err)
+	}
+
+	return nil
+}
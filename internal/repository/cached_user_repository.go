@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/lru"
+	"golang.org/x/sync/singleflight"
+)
+
+// userCacheTimeout bounds Redis calls of the user cache
+// The cache is only an optimization, so an unresponsive Redis must not hold up reads or writes
+const userCacheTimeout = 200 * time.Millisecond
+
+// userCacheInvalidationHold is how long an invalidated user cannot be cached again
+// It outlasts any load, so no instance can write back a row it read before the write
+const userCacheInvalidationHold = DefaultQueryTimeout + 2*userCacheTimeout
+
+// CachedUserRepository is a read-through cache of users by ID in front of a UserRepository
+// Users are cached in Redis, shared by all instances, and optionally in a small in-process LRU
+// with a short TTL. Every write through it invalidates both tiers; other instances' in-process
+// copies expire within the local TTL. Invalidated users cannot be cached in Redis again until
+// every load that may have read the old row is over. Concurrent misses for a user share a single load.
+// Lookups by email or identity and searches always read the database.
+type CachedUserRepository struct {
+	users           UserStore // The UserRepository reading the database
+	cacheRepository RedisCacheRepository
+	ttl             time.Duration
+	local           *lru.Cache[int64, *model.User]
+	loads           singleflight.Group
+
+	// generation changes on every invalidation so loads that overlap a write are not cached
+	generation atomic.Uint64
+}
+
+// NewCachedUserRepository creates a user cache holding users in Redis for ttl
+func NewCachedUserRepository(users UserStore, cacheRepo RedisCacheRepository, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{
+		users:           users,
+		cacheRepository: cacheRepo,
+		ttl:             ttl,
+	}
+}
+
+// WithLocalCache adds an in-process tier holding up to size users for ttl
+// Keep ttl short: invalidations only reach the local tier of the instance that made the write
+func (r *CachedUserRepository) WithLocalCache(size int, ttl time.Duration) *CachedUserRepository {
+	r.local = lru.New[int64, *model.User](size, ttl)
+	return r
+}
+
+// GetByID retrieves a user by their ID, from the cache if possible
+// Returns nil if the user does not exist; missing users are not cached
+func (r *CachedUserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	if r.local != nil {
+		if user, ok := r.local.Get(id); ok {
+			return copyUser(user), nil
+		}
+	}
+
+	// The load outlives callers that give up, so the others waiting for it still get the user
+	loadCtx := context.WithoutCancel(ctx)
+	results := r.loads.DoChan(strconv.FormatInt(id, 10), func() (interface{}, error) {
+		return r.load(loadCtx, id)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return copyUser(result.Val.(*model.User)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetByEmail retrieves a user by their email address
+func (r *CachedUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.users.GetByEmail(ctx, email)
+}
+
+// GetByIdentity retrieves the user linked to a provider identity
+func (r *CachedUserRepository) GetByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
+	return r.users.GetByIdentity(ctx, provider, subject)
+}
+
+// Search retrieves users matching the request's filters (see UserRepository.Search)
+func (r *CachedUserRepository) Search(ctx context.Context, req *model.UserSearchRequest, limit int) ([]model.User, error) {
+	return r.users.Search(ctx, req, limit)
+}
+
+// CreateWithIdentity creates a new user holding a single provider identity
+// Missing users are never cached, so there is nothing to invalidate
+func (r *CachedUserRepository) CreateWithIdentity(ctx context.Context, identity *model.Identity) (*model.User, error) {
+	return r.users.CreateWithIdentity(ctx, identity)
+}
+
+// UpdateProfile applies a profile edit and invalidates the cached user
+func (r *CachedUserRepository) UpdateProfile(ctx context.Context, id int64, update *model.UpdateProfileRequest) (*model.User, error) {
+	user, err := r.users.UpdateProfile(ctx, id, update)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+// FillProfile sets the user's empty profile fields and invalidates the cached user
+func (r *CachedUserRepository) FillProfile(ctx context.Context, id int64, profile model.Profile) (*model.User, error) {
+	user, err := r.users.FillProfile(ctx, id, profile)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+// SetStatus sets a user's account status and invalidates the cached user
+func (r *CachedUserRepository) SetStatus(ctx context.Context, id int64, status model.UserStatus, reason string, expiresAt *time.Time) (*model.User, error) {
+	user, err := r.users.SetStatus(ctx, id, status, reason, expiresAt)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+// SetEmailDeliverable records whether mail to the user is delivered and invalidates the cached user
+func (r *CachedUserRepository) SetEmailDeliverable(ctx context.Context, id int64, deliverable bool) error {
+	err := r.users.SetEmailDeliverable(ctx, id, deliverable)
+	r.invalidate(ctx, id)
+	return err
+}
+
+// Delete permanently deletes a user and invalidates the cached user
+func (r *CachedUserRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	deleted, err := r.users.Delete(ctx, id)
+	r.invalidate(ctx, id)
+	return deleted, err
+}
+
+// load reads a user from Redis, falling back to the database and caching what it finds
+func (r *CachedUserRepository) load(ctx context.Context, id int64) (*model.User, error) {
+	generation := r.generation.Load()
+
+	// The cache is an optimization: fall back to the database on any cache error
+	cacheCtx, cancel := context.WithTimeout(ctx, userCacheTimeout)
+	cached, err := r.cacheRepository.GetUserCache(cacheCtx, id)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to read cached user %d: %v", id, err)
+	}
+	if cached != nil {
+		r.cacheLocally(generation, cached)
+		return cached, nil
+	}
+
+	user, err := r.users.GetByID(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	// A write since the load started may have invalidated what was read
+	if r.generation.Load() != generation {
+		return user, nil
+	}
+
+	// Writes on other instances leave a tombstone that keeps a stale row out of Redis
+	cacheCtx, cancel = context.WithTimeout(ctx, userCacheTimeout)
+	defer cancel()
+	added, err := r.cacheRepository.AddUserCache(cacheCtx, id, user, r.ttl)
+	if err != nil {
+		log.Printf("Failed to cache user %d: %v", id, err)
+	}
+	if added {
+		r.cacheLocally(generation, user)
+	}
+
+	return user, nil
+}
+
+// cacheLocally adds a user to the in-process tier unless a write invalidated users since generation
+func (r *CachedUserRepository) cacheLocally(generation uint64, user *model.User) {
+	if r.local != nil && r.generation.Load() == generation {
+		r.local.Add(user.ID, user)
+	}
+}
+
+// invalidate drops a user from both tiers after a write, whether or not it succeeded
+// Cache entries expire on their own, so a failed purge is only logged
+func (r *CachedUserRepository) invalidate(ctx context.Context, id int64) {
+	r.generation.Add(1)
+	if r.local != nil {
+		r.local.Remove(id)
+	}
+	// Loads already in flight may have read the old row; later reads start a new one
+	r.loads.Forget(strconv.FormatInt(id, 10))
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), userCacheTimeout)
+	defer cancel()
+	if err := r.cacheRepository.InvalidateUserCache(ctx, id, userCacheInvalidationHold); err != nil {
+		log.Printf("Failed to invalidate cached user %d: %v", id, err)
+	}
+}
+
+// copyUser returns a copy of a cached user, so callers cannot modify the cached value
+func copyUser(user *model.User) *model.User {
+	if user == nil {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
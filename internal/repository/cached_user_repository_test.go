@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+)
+
+const testUserID = 7
+
+// pause blocks the first call that takes it until resumed, so a test can interleave a write with a load
+type pause struct {
+	reached chan struct{}
+	resume  chan struct{}
+}
+
+func newPause() *pause {
+	return &pause{reached: make(chan struct{}), resume: make(chan struct{})}
+}
+
+// take waits at p, if set, and clears it so later calls do not wait
+func take(mu *sync.Mutex, p **pause) {
+	mu.Lock()
+	current := *p
+	*p = nil
+	mu.Unlock()
+
+	if current != nil {
+		close(current.reached)
+		<-current.resume
+	}
+}
+
+// fakeUsers stands in for the database; every write renames the user
+type fakeUsers struct {
+	UserStore
+
+	mu       sync.Mutex
+	users    map[int64]*model.User
+	reads    int
+	writes   int
+	writeErr error
+	pause    *pause // Taken by the next GetByID after reading the row
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{users: map[int64]*model.User{testUserID: {ID: testUserID, DisplayName: "original"}}}
+}
+
+func (s *fakeUsers) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	s.mu.Lock()
+	s.reads++
+	user := copyUser(s.users[id])
+	s.mu.Unlock()
+
+	take(&s.mu, &s.pause)
+	return user, nil
+}
+
+func (s *fakeUsers) write(id int64) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writeErr != nil {
+		return nil, s.writeErr
+	}
+	s.writes++
+	s.users[id].DisplayName = fmt.Sprintf("write %d", s.writes)
+	return copyUser(s.users[id]), nil
+}
+
+func (s *fakeUsers) UpdateProfile(ctx context.Context, id int64, update *model.UpdateProfileRequest) (*model.User, error) {
+	return s.write(id)
+}
+
+func (s *fakeUsers) FillProfile(ctx context.Context, id int64, profile model.Profile) (*model.User, error) {
+	return s.write(id)
+}
+
+func (s *fakeUsers) SetStatus(ctx context.Context, id int64, status model.UserStatus, reason string, expiresAt *time.Time) (*model.User, error) {
+	return s.write(id)
+}
+
+func (s *fakeUsers) SetEmailDeliverable(ctx context.Context, id int64, deliverable bool) error {
+	_, err := s.write(id)
+	return err
+}
+
+func (s *fakeUsers) Delete(ctx context.Context, id int64) (bool, error) {
+	_, err := s.write(id)
+	return err == nil, err
+}
+
+// fakeUserCache keeps users in memory with the tombstones of RedisCacheRepository
+type fakeUserCache struct {
+	RedisCacheRepository
+
+	mu            sync.Mutex
+	users         map[int64]*model.User
+	heldUntil     map[int64]time.Time
+	reads         int
+	invalidateErr error
+	pause         *pause // Taken by the next GetUserCache after reading the entry
+}
+
+func newFakeUserCache() *fakeUserCache {
+	return &fakeUserCache{users: make(map[int64]*model.User), heldUntil: make(map[int64]time.Time)}
+}
+
+func (c *fakeUserCache) AddUserCache(ctx context.Context, userID int64, user *model.User, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.users[userID]; ok || time.Now().Before(c.heldUntil[userID]) {
+		return false, nil
+	}
+	c.users[userID] = copyUser(user)
+	return true, nil
+}
+
+func (c *fakeUserCache) GetUserCache(ctx context.Context, userID int64) (*model.User, error) {
+	c.mu.Lock()
+	c.reads++
+	user := copyUser(c.users[userID])
+	c.mu.Unlock()
+
+	take(&c.mu, &c.pause)
+	return user, nil
+}
+
+func (c *fakeUserCache) InvalidateUserCache(ctx context.Context, userID int64, hold time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.invalidateErr != nil {
+		return c.invalidateErr
+	}
+	delete(c.users, userID)
+	c.heldUntil[userID] = time.Now().Add(hold)
+	return nil
+}
+
+// cached returns the user held in Redis, or nil
+func (c *fakeUserCache) cached(userID int64) *model.User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.users[userID]
+}
+
+func TestCachedUserRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("miss reads the database and fills both tiers", func(t *testing.T) {
+		users, cache := newFakeUsers(), newFakeUserCache()
+		repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			user, err := repo.GetByID(ctx, testUserID)
+			if err != nil || user == nil || user.DisplayName != "original" {
+				t.Fatalf("read %d: user = %+v, err = %v", i+1, user, err)
+			}
+		}
+		if users.reads != 1 || cache.reads != 1 {
+			t.Errorf("database reads = %d, Redis reads = %d, want 1 and 1", users.reads, cache.reads)
+		}
+		if cache.cached(testUserID) == nil {
+			t.Error("user not cached in Redis")
+		}
+	})
+
+	t.Run("Redis hit skips the database", func(t *testing.T) {
+		users, cache := newFakeUsers(), newFakeUserCache()
+		cache.users[testUserID] = &model.User{ID: testUserID, DisplayName: "cached"}
+		repo := NewCachedUserRepository(users, cache, time.Minute)
+
+		user, err := repo.GetByID(ctx, testUserID)
+		if err != nil || user.DisplayName != "cached" {
+			t.Fatalf("user = %+v, err = %v", user, err)
+		}
+		if users.reads != 0 {
+			t.Errorf("database reads = %d, want 0", users.reads)
+		}
+	})
+
+	t.Run("missing users are not cached", func(t *testing.T) {
+		users, cache := newFakeUsers(), newFakeUserCache()
+		repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			if user, err := repo.GetByID(ctx, 99); err != nil || user != nil {
+				t.Fatalf("read %d: user = %+v, err = %v", i+1, user, err)
+			}
+		}
+		if users.reads != 2 {
+			t.Errorf("database reads = %d, want 2", users.reads)
+		}
+	})
+
+	t.Run("callers cannot modify the cached user", func(t *testing.T) {
+		users, cache := newFakeUsers(), newFakeUserCache()
+		repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+		user, _ := repo.GetByID(ctx, testUserID)
+		user.DisplayName = "modified"
+		if again, _ := repo.GetByID(ctx, testUserID); again.DisplayName != "original" {
+			t.Errorf("display name = %q, want %q", again.DisplayName, "original")
+		}
+	})
+}
+
+func TestCachedUserRepository_WritesInvalidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, repo *CachedUserRepository) error
+	}{
+		{"update profile", func(ctx context.Context, repo *CachedUserRepository) error {
+			_, err := repo.UpdateProfile(ctx, testUserID, &model.UpdateProfileRequest{})
+			return err
+		}},
+		{"fill profile", func(ctx context.Context, repo *CachedUserRepository) error {
+			_, err := repo.FillProfile(ctx, testUserID, model.Profile{})
+			return err
+		}},
+		{"set status", func(ctx context.Context, repo *CachedUserRepository) error {
+			_, err := repo.SetStatus(ctx, testUserID, model.UserStatusSuspended, "", nil)
+			return err
+		}},
+		{"set email deliverable", func(ctx context.Context, repo *CachedUserRepository) error {
+			return repo.SetEmailDeliverable(ctx, testUserID, false)
+		}},
+		{"delete", func(ctx context.Context, repo *CachedUserRepository) error {
+			_, err := repo.Delete(ctx, testUserID)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users, cache := newFakeUsers(), newFakeUserCache()
+			repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+			if _, err := repo.GetByID(ctx, testUserID); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.write(ctx, repo); err != nil {
+				t.Fatal(err)
+			}
+			if cache.cached(testUserID) != nil {
+				t.Error("user still cached in Redis after the write")
+			}
+
+			user, err := repo.GetByID(ctx, testUserID)
+			if err != nil || user.DisplayName != "write 1" {
+				t.Fatalf("read after the write: user = %+v, err = %v", user, err)
+			}
+			if users.reads != 2 {
+				t.Errorf("database reads = %d, want 2", users.reads)
+			}
+
+			// Until the hold is over Redis refuses the user, in case another instance's load read the old row
+			if cache.cached(testUserID) != nil {
+				t.Error("user cached in Redis again within the invalidation hold")
+			}
+		})
+	}
+}
+
+func TestCachedUserRepository_FailedWriteInvalidates(t *testing.T) {
+	ctx := context.Background()
+	users, cache := newFakeUsers(), newFakeUserCache()
+	repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+	repo.GetByID(ctx, testUserID)
+
+	// The write may have been applied even if its result was lost
+	users.writeErr = errors.New("connection reset")
+	if _, err := repo.SetStatus(ctx, testUserID, model.UserStatusBanned, "", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := repo.local.Get(testUserID); ok {
+		t.Error("user still cached locally")
+	}
+	if cache.cached(testUserID) != nil {
+		t.Error("user still cached in Redis")
+	}
+}
+
+func TestCachedUserRepository_LoadOverlappingWriteIsNotCached(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// Redis already holds the old row; the write lands while it is being read
+		redisHit bool
+
+		// Redis keeps no tombstone, so only the generation check keeps the old row out
+		invalidateErr error
+	}{
+		{name: "database read"},
+		{name: "database read without a tombstone", invalidateErr: errors.New("connection refused")},
+		{name: "Redis read", redisHit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users, cache := newFakeUsers(), newFakeUserCache()
+			repo := NewCachedUserRepository(users, cache, time.Minute).WithLocalCache(10, time.Minute)
+
+			load := newPause()
+			if tt.redisHit {
+				cache.users[testUserID] = copyUser(users.users[testUserID])
+				cache.pause = load
+			} else {
+				users.pause = load
+			}
+
+			// A read starts loading the old row...
+			done := make(chan *model.User)
+			go func() {
+				user, err := repo.GetByID(ctx, testUserID)
+				if err != nil {
+					t.Error(err)
+				}
+				done <- user
+			}()
+			<-load.reached
+
+			// ...a write lands before the load is over...
+			cache.invalidateErr = tt.invalidateErr
+			if _, err := repo.SetStatus(ctx, testUserID, model.UserStatusSuspended, "", nil); err != nil {
+				t.Fatal(err)
+			}
+			close(load.resume)
+			if user := <-done; user.DisplayName != "original" {
+				t.Fatalf("overlapping read: display name = %q, want the row it read", user.DisplayName)
+			}
+
+			// ...and the old row is cached in neither tier
+			if _, ok := repo.local.Get(testUserID); ok {
+				t.Error("old row cached locally")
+			}
+			if cached := cache.cached(testUserID); cached != nil && cached.DisplayName == "original" {
+				t.Error("old row cached in Redis")
+			}
+			if user, err := repo.GetByID(ctx, testUserID); err != nil || user.DisplayName != "write 1" {
+				t.Errorf("read after the write: user = %+v, err = %v", user, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the PostgreSQL error code for unique constraint violations
+const uniqueViolation = "23505"
+
+var (
+	// ErrIdentityTaken is returned when linking an identity that belongs to another user
+	ErrIdentityTaken = errors.New("identity is linked to another user")
+
+	// ErrProviderLinked is returned when the user already has an identity at the provider
+	ErrProviderLinked = errors.New("user already has an identity at this provider")
+
+	// ErrLastIdentity is returned when unlinking would leave the user without a login method
+	ErrLastIdentity = errors.New("cannot unlink the last identity")
+)
+
+// identityColumns is the column list selected for model.Identity, in scanIdentity order
+const identityColumns = `id, user_id, provider, subject, COALESCE(email, ''), email_verified, linked_at, last_used_at, disabled_at`
+
+// scanIdentity scans a row selected with identityColumns into an identity
+func scanIdentity(row pgx.Row) (*model.Identity, error) {
+	var identity model.Identity
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.EmailVerified,
+		&identity.LinkedAt,
+		&identity.LastUsedAt,
+		&identity.DisabledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// IdentityRepository handles database operations for provider identities linked to users
+type IdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{
+		db: db,
+	}
+}
+
+// GetByProviderSubject retrieves an identity by provider and subject
+func (r *IdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + identityColumns + `
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	identity, err := scanIdentity(r.db.QueryRow(ctx, query, provider, subject))
+
+	if err == pgx.ErrNoRows {
+		return nil, nil // Identity not found
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// MarkUsed records that an identity was just used to sign in
+func (r *IdentityRepository) MarkUsed(ctx context.Context, id int64) error {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `UPDATE user_identities SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark identity used: %w", err)
+	}
+
+	return nil
+}
+
+// SetDisabled locks or unlocks sign-in with an identity
+func (r *IdentityRepository) SetDisabled(ctx context.Context, provider, subject string, disabled bool) error {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	// Keep the original timestamp when an already disabled account is reported again
+	query := `
+		UPDATE user_identities
+		SET disabled_at = CASE WHEN $3 THEN COALESCE(disabled_at, CURRENT_TIMESTAMP) END
+		WHERE provider = $1 AND subject = $2
+	`
+
+	if _, err := r.db.Exec(ctx, query, provider, subject, disabled); err != nil {
+		return fmt.Errorf("failed to set identity disabled: %w", err)
+	}
+
+	return nil
+}
+
+// ListByUser retrieves every identity linked to a user, oldest first
+func (r *IdentityRepository) ListByUser(ctx context.Context, userID int64) ([]model.Identity, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + identityColumns + `
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY linked_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []model.Identity{}
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, *identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// Link links an identity to a user
+// Returns ErrIdentityTaken if the identity belongs to another user
+// and ErrProviderLinked if the user already has an identity at the provider
+func (r *IdentityRepository) Link(ctx context.Context, userID int64, identity *model.Identity) (*model.Identity, error) {
+	// Validate input
+	if err := validator.ValidateProvider(identity.Provider); err != nil {
+		return nil, fmt.Errorf("invalid provider: %w", err)
+	}
+
+	if err := validator.ValidateSubject(identity.Subject); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified, last_used_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, CURRENT_TIMESTAMP)
+		RETURNING ` + identityColumns
+
+	linked, err := scanIdentity(r.db.QueryRow(ctx, query,
+		userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified,
+	))
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		switch pgErr.ConstraintName {
+		case "user_identities_provider_subject_unique":
+			return nil, ErrIdentityTaken
+		case "user_identities_user_provider_unique":
+			return nil, ErrProviderLinked
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return linked, nil
+}
+
+// DeleteUnlessLast unlinks the user's identity at a provider
+// Returns false if no such identity exists and ErrLastIdentity if it is the user's only login method
+func (r *IdentityRepository) DeleteUnlessLast(ctx context.Context, userID int64, provider string) (bool, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	// Lock the user so concurrent unlinks cannot both see another remaining identity
+	var locked int64
+	err = tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&locked)
+	if err == pgx.ErrNoRows {
+		return false, nil // User not found
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var total int
+	var linked bool
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(BOOL_OR(provider = $2), FALSE)
+		FROM user_identities
+		WHERE user_id = $1
+	`, userID, provider).Scan(&total, &linked)
+	if err != nil {
+		return false, fmt.Errorf("failed to count identities: %w", err)
+	}
+
+	if !linked {
+		return false, nil
+	}
+	if total <= 1 {
+		return false, ErrLastIdentity
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete identity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
@@ -15,6 +15,9 @@ type RedisTokenRepository interface {
 	// GetRefreshToken retrieves a refresh token from Redis
 	GetRefreshToken(ctx context.Context, userID int64, tokenID string) (string, error)
 
+	// ConsumeRefreshToken atomically retrieves and deletes a refresh token (empty if missing or expired)
+	ConsumeRefreshToken(ctx context.Context, userID int64, tokenID string) (string, error)
+
 	// DeleteRefreshToken removes a refresh token from Redis
 	DeleteRefreshToken(ctx context.Context, userID int64, tokenID string) error
 
@@ -29,35 +32,72 @@ type RedisBlacklistRepository interface {
 
 	// IsBlacklisted checks if a token is blacklisted
 	IsBlacklisted(ctx context.Context, tokenID string) (bool, error)
+
+	// RevokeUserTokens revokes every token issued to a user up to revokedAt (in milliseconds)
+	RevokeUserTokens(ctx context.Context, userID int64, revokedAt time.Time, ttl time.Duration) error
+
+	// RevokeTokenFamily revokes every token belonging to a refresh token family
+	RevokeTokenFamily(ctx context.Context, familyID string, ttl time.Duration) error
+
+	// IsTokenRevoked checks if a token is blacklisted or covered by a family or per-user revocation
+	IsTokenRevoked(ctx context.Context, tokenID, familyID string, userID int64, issuedAt time.Time) (bool, error)
+}
+
+// RedisLinkTicketRepository defines operations for pending account linking tickets
+type RedisLinkTicketRepository interface {
+	// StoreLinkTicket stores a linking ticket with TTL
+	StoreLinkTicket(ctx context.Context, ticketID string, ticket *model.LinkTicket, ttl time.Duration) error
+
+	// ConsumeLinkTicket atomically retrieves and deletes a linking ticket (nil if missing or expired)
+	ConsumeLinkTicket(ctx context.Context, ticketID string) (*model.LinkTicket, error)
+}
+
+// RedisWebhookEventRepository defines operations for detecting replayed webhook events
+// Events are identified by their source (e.g. "google_risc") and the ID the provider gave them
+type RedisWebhookEventRepository interface {
+	// ClaimEvent records an event as processed for ttl; returns false if it already was
+	ClaimEvent(ctx context.Context, source, eventID string, ttl time.Duration) (bool, error)
+
+	// ReleaseEvent forgets a claimed event, so a redelivery is processed again
+	ReleaseEvent(ctx context.Context, source, eventID string) error
 }
 
 // RedisRateLimitRepository defines operations for rate limiting
+// Counters are kept per policy, so routes with different limits do not share them
 type RedisRateLimitRepository interface {
-	// IncrementUserRequest increments the request count for a user
-	// Returns: current count, reset time, error
-	IncrementUserRequest(ctx context.Context, userID int64, window time.Duration) (int64, time.Time, error)
+	// AllowUserRequest counts a request of a user against a rate limit
+	AllowUserRequest(ctx context.Context, limit model.RateLimit, userID int64) (*model.RateLimitResult, error)
+
+	// AllowIPRequest counts a request from an IP address against a rate limit
+	AllowIPRequest(ctx context.Context, limit model.RateLimit, ipAddress string) (*model.RateLimitResult, error)
+}
+
+// RedisLockoutRepository defines operations for counting failed authentication attempts
+// Keys name what attempts are counted against, e.g. "ip:192.168.1.100"
+type RedisLockoutRepository interface {
+	// RecordFailure increments the failure count of a key, which is forgotten window after the last failure
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
 
-	// IncrementIPRequest increments the request count for an IP address
-	// Returns: current count, reset time, error
-	IncrementIPRequest(ctx context.Context, ipAddress string, window time.Duration) (int64, time.Time, error)
+	// GetFailures returns the failure count of a key and how long it stays locked out (0 if it is not)
+	GetFailures(ctx context.Context, key string) (int64, time.Duration, error)
 
-	// GetUserRequestCount gets the current request count for a user
-	GetUserRequestCount(ctx context.Context, userID int64) (int64, error)
+	// Lock locks a key out for duration, keeping its failure count until window after the lockout ends
+	Lock(ctx context.Context, key string, duration, window time.Duration) error
 
-	// GetIPRequestCount gets the current request count for an IP
-	GetIPRequestCount(ctx context.Context, ipAddress string) (int64, error)
+	// ResetFailures clears the failure count and lockout of a key
+	ResetFailures(ctx context.Context, key string) error
 }
 
 // RedisCacheRepository defines operations for caching
 type RedisCacheRepository interface {
-	// SetUserCache stores user data in cache with TTL
-	SetUserCache(ctx context.Context, userID int64, user *model.User, ttl time.Duration) error
+	// AddUserCache stores user data in cache with TTL unless the user is cached or was just invalidated
+	AddUserCache(ctx context.Context, userID int64, user *model.User, ttl time.Duration) (bool, error)
 
-	// GetUserCache retrieves user data from cache
+	// GetUserCache retrieves user data from cache, or nil if the user is not cached
 	GetUserCache(ctx context.Context, userID int64) (*model.User, error)
 
-	// InvalidateUserCache removes user data from cache
-	InvalidateUserCache(ctx context.Context, userID int64) error
+	// InvalidateUserCache removes user data from cache and keeps AddUserCache from storing it for hold
+	InvalidateUserCache(ctx context.Context, userID int64, hold time.Duration) error
 
 	// SetGeneric stores any JSON-serializable data in cache
 	SetGeneric(ctx context.Context, key string, value interface{}, ttl time.Duration) error
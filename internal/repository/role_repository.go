@@ -0,0 +1,466 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// foreignKeyViolation is the PostgreSQL error code for foreign key constraint violations
+const foreignKeyViolation = "23503"
+
+var (
+	// ErrRoleNotFound is returned when a role does not exist
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrRoleExists is returned when creating a role whose name is taken
+	ErrRoleExists = errors.New("role already exists")
+
+	// ErrSystemRole is returned when changing or deleting a role managed by migrations
+	ErrSystemRole = errors.New("system roles cannot be changed")
+
+	// ErrUnknownPermission is returned when granting a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+
+	// ErrLastAdmin is returned when unassigning the admin role from its only holder
+	ErrLastAdmin = errors.New("cannot unassign the last admin")
+)
+
+// roleColumns is the column list selected for model.Role from roles r, in scanRole order
+// Permissions are aggregated, so queries selecting it must GROUP BY r.id
+const roleColumns = `r.id, r.name, r.description, r.system, r.created_at,
+	COALESCE(ARRAY_AGG(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')`
+
+// scanRole scans a row selected with roleColumns into a role
+func scanRole(row pgx.Row) (*model.Role, error) {
+	var role model.Role
+	err := row.Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.System,
+		&role.CreatedAt,
+		&role.Permissions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// RoleRepository handles database operations for roles, permissions and role assignments
+type RoleRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *pgxpool.Pool) *RoleRepository {
+	return &RoleRepository{
+		db: db,
+	}
+}
+
+// ListPermissions retrieves every permission roles can grant, by name
+func (r *RoleRepository) ListPermissions(ctx context.Context) ([]model.Permission, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `SELECT name, description FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []model.Permission{}
+	for rows.Next() {
+		var permission model.Permission
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// ListRoles retrieves every role with its permissions, by name
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]model.Role, error) {
+	return r.listRoles(ctx, `
+		SELECT `+roleColumns+`
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		GROUP BY r.id
+		ORDER BY r.name
+	`)
+}
+
+// GetRole retrieves a role with its permissions, or nil if it does not exist
+func (r *RoleRepository) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	roles, err := r.listRoles(ctx, `
+		SELECT `+roleColumns+`
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE r.name = $1
+		GROUP BY r.id
+	`, name)
+	if err != nil || len(roles) == 0 {
+		return nil, err
+	}
+	return &roles[0], nil
+}
+
+// ListUserRoles retrieves the roles assigned to a user, by name
+func (r *RoleRepository) ListUserRoles(ctx context.Context, userID int64) ([]model.Role, error) {
+	return r.listRoles(ctx, `
+		SELECT `+roleColumns+`
+		FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id AND ur.user_id = $1
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		GROUP BY r.id
+		ORDER BY r.name
+	`, userID)
+}
+
+// listRoles runs a query selecting roleColumns
+func (r *RoleRepository) listRoles(ctx context.Context, query string, args ...any) ([]model.Role, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []model.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GetAuthorization retrieves the names of a user's roles and the permissions they grant
+func (r *RoleRepository) GetAuthorization(ctx context.Context, userID int64) (*model.Authorization, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			COALESCE(ARRAY_AGG(DISTINCT r.name), '{}'),
+			COALESCE(ARRAY_AGG(DISTINCT rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE ur.user_id = $1
+	`
+
+	var authorization model.Authorization
+	err := r.db.QueryRow(ctx, query, userID).Scan(&authorization.Roles, &authorization.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorization: %w", err)
+	}
+
+	return &authorization, nil
+}
+
+// CreateRole creates a role granting the given permissions
+// Returns ErrRoleExists if the name is taken and ErrUnknownPermission if a permission does not exist
+func (r *RoleRepository) CreateRole(ctx context.Context, name, description string, permissions []string) (*model.Role, error) {
+	// Validate input
+	if err := validator.ValidateRoleName(name); err != nil {
+		return nil, fmt.Errorf("invalid role name: %w", err)
+	}
+
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	var roleID int64
+	err = tx.QueryRow(ctx, `INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING id`, name, description).Scan(&roleID)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, ErrRoleExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	if err := grantPermissions(ctx, tx, roleID, permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := getRole(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return role, nil
+}
+
+// SetRolePermissions replaces the permissions a role grants
+// Returns the updated role and the IDs of the users holding it.
+// Returns ErrRoleNotFound, ErrSystemRole or ErrUnknownPermission.
+func (r *RoleRepository) SetRolePermissions(ctx context.Context, name string, permissions []string) (*model.Role, []int64, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	roleID, err := lockEditableRole(ctx, tx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete role permissions: %w", err)
+	}
+
+	if err := grantPermissions(ctx, tx, roleID, permissions); err != nil {
+		return nil, nil, err
+	}
+
+	role, err := getRole(ctx, tx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userIDs, err := roleHolders(ctx, tx, roleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return role, userIDs, nil
+}
+
+// DeleteRole deletes a role, unassigning it from every user
+// Returns the IDs of the users that held it. Returns ErrRoleNotFound or ErrSystemRole.
+func (r *RoleRepository) DeleteRole(ctx context.Context, name string) ([]int64, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	roleID, err := lockEditableRole(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := roleHolders(ctx, tx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM roles WHERE id = $1`, roleID); err != nil {
+		return nil, fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// AssignRole assigns a role to a user; grantedBy is the ID of the user assigning it
+// Returns false if the user already holds the role and ErrRoleNotFound if it does not exist
+func (r *RoleRepository) AssignRole(ctx context.Context, userID int64, name string, grantedBy int64) (bool, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var roleID int64
+	err := r.db.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1`, name).Scan(&roleID)
+	if err == pgx.ErrNoRows {
+		return false, ErrRoleNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id, granted_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, roleID, grantedBy)
+	if err != nil {
+		return false, fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// UnassignRole removes a role from a user
+// Returns false if the user does not hold the role and ErrLastAdmin if the user is the only admin
+func (r *RoleRepository) UnassignRole(ctx context.Context, userID int64, name string) (bool, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	// Lock the role so concurrent unassignments cannot remove the last two admins together
+	var roleID int64
+	err = tx.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1 FOR UPDATE`, name).Scan(&roleID)
+	if err == pgx.ErrNoRows {
+		return false, nil // A missing role is not held
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock role: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`, userID, roleID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unassign role: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if name == model.RoleAdmin {
+		var held bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_roles WHERE role_id = $1)`, roleID).Scan(&held)
+		if err != nil {
+			return false, fmt.Errorf("failed to count admins: %w", err)
+		}
+		if !held {
+			return false, ErrLastAdmin
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// getRole retrieves a role by name within a transaction
+func getRole(ctx context.Context, tx pgx.Tx, name string) (*model.Role, error) {
+	query := `
+		SELECT ` + roleColumns + `
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE r.name = $1
+		GROUP BY r.id
+	`
+
+	role, err := scanRole(tx.QueryRow(ctx, query, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// lockEditableRole locks a role for changes and returns its ID
+// Returns ErrRoleNotFound if it does not exist and ErrSystemRole if it is managed by migrations
+func lockEditableRole(ctx context.Context, tx pgx.Tx, name string) (int64, error) {
+	var roleID int64
+	var system bool
+	err := tx.QueryRow(ctx, `SELECT id, system FROM roles WHERE name = $1 FOR UPDATE`, name).Scan(&roleID, &system)
+	if err == pgx.ErrNoRows {
+		return 0, ErrRoleNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock role: %w", err)
+	}
+
+	if system {
+		return 0, ErrSystemRole
+	}
+
+	return roleID, nil
+}
+
+// grantPermissions adds permissions to a role within a transaction
+// Returns ErrUnknownPermission if a permission does not exist
+func grantPermissions(ctx context.Context, tx pgx.Tx, roleID int64, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission)
+		SELECT $1, UNNEST($2::text[])
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.Exec(ctx, query, roleID, permissions)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return ErrUnknownPermission
+	}
+	if err != nil {
+		return fmt.Errorf("failed to grant permissions: %w", err)
+	}
+
+	return nil
+}
+
+// roleHolders retrieves the IDs of the users holding a role within a transaction
+func roleHolders(ctx context.Context, tx pgx.Tx, roleID int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, `SELECT user_id FROM user_roles WHERE role_id = $1`, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role holders: %w", err)
+	}
+
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role holders: %w", err)
+	}
+
+	return userIDs, nil
+}
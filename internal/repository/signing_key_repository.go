@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/encryption"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// signingKeyLockID is the advisory lock serializing key ring changes across instances
+const signingKeyLockID = 0x6b657972696e67 // "keyring"
+
+// SigningKeyRepository handles database operations for token signing keys
+// Private keys are encrypted before they are written and decrypted after they are read
+type SigningKeyRepository struct {
+	db     *pgxpool.Pool
+	cipher *encryption.Cipher
+}
+
+// NewSigningKeyRepository creates a new signing key repository
+func NewSigningKeyRepository(db *pgxpool.Pool, cipher *encryption.Cipher) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// ListUsable returns the keys that have not expired at now, oldest activation first
+func (r *SigningKeyRepository) ListUsable(ctx context.Context, now time.Time) ([]model.SigningKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT kid, algorithm, encrypted_private_key, created_at, activates_at, expires_at
+		FROM signing_keys
+		WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY activates_at
+	`
+
+	rows, err := r.db.Query(ctx, query, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.SigningKey
+	for rows.Next() {
+		var (
+			key       model.SigningKey
+			encrypted string
+		)
+		if err := rows.Scan(&key.ID, &key.Algorithm, &encrypted, &key.CreatedAt, &key.ActivatesAt, &key.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+
+		privateKeyPEM, err := r.cipher.Decrypt(encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", key.ID, err)
+		}
+		key.PrivateKeyPEM = []byte(privateKeyPEM)
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// CreateFirst stores the first key of the ring
+// Returns false without storing it if the ring already has a usable key
+func (r *SigningKeyRepository) CreateFirst(ctx context.Context, key *model.SigningKey, now time.Time) (bool, error) {
+	return r.withLock(ctx, func(ctx context.Context, tx pgx.Tx) (bool, error) {
+		var exists bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM signing_keys WHERE expires_at IS NULL OR expires_at > $1)
+		`, now.UTC()).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("failed to check signing keys: %w", err)
+		}
+		if exists {
+			return false, nil
+		}
+
+		return true, r.insert(ctx, tx, key)
+	})
+}
+
+// Rotate stores a new key and schedules every older key to expire at retireAt
+// If notActivatedAfter is non-zero, the rotation is skipped (returning false) when a key
+// activating after it exists, so concurrent scheduled rotations on several instances add one key
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *model.SigningKey, retireAt, notActivatedAfter time.Time) (bool, error) {
+	return r.withLock(ctx, func(ctx context.Context, tx pgx.Tx) (bool, error) {
+		if !notActivatedAfter.IsZero() {
+			var recent bool
+			err := tx.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM signing_keys WHERE activates_at > $1)
+			`, notActivatedAfter.UTC()).Scan(&recent)
+			if err != nil {
+				return false, fmt.Errorf("failed to check signing keys: %w", err)
+			}
+			if recent {
+				return false, nil
+			}
+		}
+
+		_, err := tx.Exec(ctx, `
+			UPDATE signing_keys SET expires_at = $1 WHERE expires_at IS NULL
+		`, retireAt.UTC())
+		if err != nil {
+			return false, fmt.Errorf("failed to retire signing keys: %w", err)
+		}
+
+		return true, r.insert(ctx, tx, key)
+	})
+}
+
+// DeleteExpired removes keys that expired before now
+func (r *SigningKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `DELETE FROM signing_keys WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// insert encrypts and inserts a key within a transaction
+func (r *SigningKeyRepository) insert(ctx context.Context, tx pgx.Tx, key *model.SigningKey) error {
+	encrypted, err := r.cipher.Encrypt(string(key.PrivateKeyPEM))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO signing_keys (kid, algorithm, encrypted_private_key, activates_at)
+		VALUES ($1, $2, $3, $4)
+	`, key.ID, key.Algorithm, encrypted, key.ActivatesAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert signing key: %w", err)
+	}
+
+	return nil
+}
+
+// withLock runs fn in a transaction holding the key ring advisory lock, committing if it returns true
+func (r *SigningKeyRepository) withLock(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) (bool, error)) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+		return false, fmt.Errorf("failed to lock signing keys: %w", err)
+	}
+
+	changed, err := fn(ctx, tx)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
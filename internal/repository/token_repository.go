@@ -5,8 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"time"
 
+	"github.com/Hamid207/ai-code-test1/internal/model"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -23,25 +23,34 @@ func NewTokenRepository(db *pgxpool.Pool) *TokenRepository {
 	}
 }
 
-// hashToken creates a SHA256 hash of the token for storage
-func hashToken(token string) string {
+// HashToken creates a SHA256 hash of the token for storage
+func HashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
 }
 
 // StoreRefreshToken stores a refresh token in the database
-func (r *TokenRepository) StoreRefreshToken(ctx context.Context, userID int64, token string, expiresAt time.Time) error {
+// record carries the token metadata: user, JWT ID (jti), family, expiry and client details
+func (r *TokenRepository) StoreRefreshToken(ctx context.Context, token string, record *model.RefreshToken) error {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	tokenHash := hashToken(token)
+	tokenHash := HashToken(token)
 
 	query := `
-		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO refresh_tokens (user_id, token_hash, token_id, token_family, expires_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
 	`
 
-	_, err := r.db.Exec(ctx, query, userID, tokenHash, expiresAt)
+	_, err := r.db.Exec(ctx, query,
+		record.UserID,
+		tokenHash,
+		record.TokenID,
+		record.FamilyID,
+		record.ExpiresAt,
+		record.IPAddress,
+		record.UserAgent,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to store refresh token: %w", err)
 	}
@@ -49,66 +58,100 @@ func (r *TokenRepository) StoreRefreshToken(ctx context.Context, userID int64, t
 	return nil
 }
 
-// ValidateRefreshToken validates a refresh token and returns the associated user ID
-func (r *TokenRepository) ValidateRefreshToken(ctx context.Context, token string) (int64, error) {
+// FindRefreshToken retrieves a refresh token record, including revoked and expired ones
+// Returns nil if the token is unknown
+func (r *TokenRepository) FindRefreshToken(ctx context.Context, token string) (*model.RefreshToken, error) {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	tokenHash := hashToken(token)
+	tokenHash := HashToken(token)
 
 	query := `
-		SELECT user_id, expires_at, revoked_at
+		SELECT id, user_id, token_hash, COALESCE(token_id, ''), token_family,
+		       COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		       expires_at, created_at, revoked_at, COALESCE(revoked_reason, ''), last_used_at
 		FROM refresh_tokens
 		WHERE token_hash = $1
 	`
 
-	var userID int64
-	var expiresAt time.Time
-	var revokedAt *time.Time
+	var rt model.RefreshToken
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&rt.ID,
+		&rt.UserID,
+		&rt.TokenHash,
+		&rt.TokenID,
+		&rt.FamilyID,
+		&rt.IPAddress,
+		&rt.UserAgent,
+		&rt.ExpiresAt,
+		&rt.CreatedAt,
+		&rt.RevokedAt,
+		&rt.RevokedReason,
+		&rt.LastUsedAt,
+	)
 
-	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&userID, &expiresAt, &revokedAt)
 	if err == pgx.ErrNoRows {
-		return 0, fmt.Errorf("refresh token not found")
+		return nil, nil // Token not found
 	}
+
 	if err != nil {
-		return 0, fmt.Errorf("failed to validate refresh token: %w", err)
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
 	}
 
-	// Check if token is revoked
-	if revokedAt != nil {
-		return 0, fmt.Errorf("refresh token has been revoked")
-	}
+	return &rt, nil
+}
 
-	// Check if token is expired
-	if time.Now().After(expiresAt) {
-		return 0, fmt.Errorf("refresh token has expired")
-	}
+// RotateRefreshToken atomically revokes an active refresh token and stores its replacement
+// Both happen in one statement, so the new token only exists if the old one was consumed
+// Returns false if the old token was already revoked or has expired (e.g. a concurrent rotation won)
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, token, newToken string, record *model.RefreshToken) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
 
-	// Update last_used_at
-	updateQuery := `
-		UPDATE refresh_tokens
-		SET last_used_at = CURRENT_TIMESTAMP
-		WHERE token_hash = $1
+	query := `
+		WITH consumed AS (
+			UPDATE refresh_tokens
+			SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $9, last_used_at = CURRENT_TIMESTAMP
+			WHERE token_hash = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			RETURNING user_id
+		)
+		INSERT INTO refresh_tokens (user_id, token_hash, token_id, token_family, expires_at, ip_address, user_agent)
+		SELECT user_id, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, '')
+		FROM consumed
 	`
-	_, _ = r.db.Exec(ctx, updateQuery, tokenHash)
 
-	return userID, nil
+	result, err := r.db.Exec(ctx, query,
+		HashToken(token),
+		record.UserID,
+		HashToken(newToken),
+		record.TokenID,
+		record.FamilyID,
+		record.ExpiresAt,
+		record.IPAddress,
+		record.UserAgent,
+		model.RefreshTokenRotated,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
 }
 
-// RevokeRefreshToken revokes a specific refresh token
+// RevokeRefreshToken revokes a specific refresh token, ending its session
 func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, token string) error {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	tokenHash := hashToken(token)
+	tokenHash := HashToken(token)
 
 	query := `
 		UPDATE refresh_tokens
-		SET revoked_at = CURRENT_TIMESTAMP
+		SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $2
 		WHERE token_hash = $1 AND revoked_at IS NULL
 	`
 
-	result, err := r.db.Exec(ctx, query, tokenHash)
+	result, err := r.db.Exec(ctx, query, tokenHash, model.RefreshTokenLogout)
 	if err != nil {
 		return fmt.Errorf("failed to revoke refresh token: %w", err)
 	}
@@ -121,18 +164,18 @@ func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, token string)
 	return nil
 }
 
-// RevokeAllUserTokens revokes all refresh tokens for a specific user
+// RevokeAllUserTokens revokes all refresh tokens for a specific user, ending their sessions
 func (r *TokenRepository) RevokeAllUserTokens(ctx context.Context, userID int64) error {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
 	query := `
 		UPDATE refresh_tokens
-		SET revoked_at = CURRENT_TIMESTAMP
+		SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $2
 		WHERE user_id = $1 AND revoked_at IS NULL
 	`
 
-	_, err := r.db.Exec(ctx, query, userID)
+	_, err := r.db.Exec(ctx, query, userID, model.RefreshTokenLogout)
 	if err != nil {
 		return fmt.Errorf("failed to revoke user tokens: %w", err)
 	}
@@ -140,6 +183,82 @@ func (r *TokenRepository) RevokeAllUserTokens(ctx context.Context, userID int64)
 	return nil
 }
 
+// RevokeTokenFamily revokes every active refresh token in one of the user's token families, ending the session
+// Returns the IDs (jti) of the tokens revoked (none if the family does not belong to the user)
+func (r *TokenRepository) RevokeTokenFamily(ctx context.Context, userID int64, familyID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $3
+		WHERE user_id = $1 AND token_family = $2 AND revoked_at IS NULL
+		RETURNING COALESCE(token_id, '')
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, familyID, model.RefreshTokenLogout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	tokenIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	return tokenIDs, nil
+}
+
+// ListActiveSessions returns the user's active sessions, most recently used first
+// A session is the active (non-revoked, non-expired) refresh token of a token family
+func (r *TokenRepository) ListActiveSessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	// The active token was issued by the latest sign-in or rotation, so its
+	// created_at is the session's last use; the family's oldest token marks the sign-in
+	query := `
+		SELECT t.token_family,
+		       COALESCE(t.user_agent, ''),
+		       COALESCE(t.ip_address, ''),
+		       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.token_family = t.token_family),
+		       t.created_at,
+		       t.expires_at
+		FROM refresh_tokens t
+		WHERE t.user_id = $1 AND t.revoked_at IS NULL AND t.expires_at > CURRENT_TIMESTAMP
+		ORDER BY t.created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []model.Session{}
+	for rows.Next() {
+		var session model.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.Device,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
 // CleanupExpiredTokens removes expired refresh tokens from the database
 func (r *TokenRepository) CleanupExpiredTokens(ctx context.Context) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Hamid207/ai-code-test1/internal/model"
@@ -16,6 +18,57 @@ const (
 	DefaultQueryTimeout = 5 * time.Second
 )
 
+// ErrEmailTaken is returned when creating a user whose email belongs to another user
+var ErrEmailTaken = errors.New("email belongs to another user")
+
+// userColumns is the column list selected for model.User, in scanUser order
+// Unset profile fields are selected as empty strings and expired statuses as active
+const userColumns = `id, email, email_deliverable,
+	COALESCE(display_name, ''), COALESCE(avatar_url, ''), COALESCE(locale, ''),
+	CASE WHEN status_expires_at <= CURRENT_TIMESTAMP THEN 'active' ELSE status END,
+	CASE WHEN status_expires_at <= CURRENT_TIMESTAMP THEN '' ELSE COALESCE(status_reason, '') END,
+	CASE WHEN status_expires_at <= CURRENT_TIMESTAMP THEN NULL ELSE status_expires_at END,
+	status_changed_at,
+	created_at, updated_at`
+
+// scanUser scans a row selected with userColumns into a user
+func scanUser(row pgx.Row) (*model.User, error) {
+	var user model.User
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.EmailDeliverable,
+		&user.DisplayName,
+		&user.AvatarURL,
+		&user.Locale,
+		&user.Status,
+		&user.StatusReason,
+		&user.StatusExpiresAt,
+		&user.StatusChangedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserStore reads and writes users
+// Implemented by UserRepository and by CachedUserRepository, which caches GetByID in front of it
+type UserStore interface {
+	GetByID(ctx context.Context, id int64) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByIdentity(ctx context.Context, provider, subject string) (*model.User, error)
+	Search(ctx context.Context, req *model.UserSearchRequest, limit int) ([]model.User, error)
+	CreateWithIdentity(ctx context.Context, identity *model.Identity) (*model.User, error)
+	UpdateProfile(ctx context.Context, id int64, update *model.UpdateProfileRequest) (*model.User, error)
+	FillProfile(ctx context.Context, id int64, profile model.Profile) (*model.User, error)
+	SetStatus(ctx context.Context, id int64, status model.UserStatus, reason string, expiresAt *time.Time) (*model.User, error)
+	SetEmailDeliverable(ctx context.Context, id int64, deliverable bool) error
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
 // UserRepository handles database operations for users
 type UserRepository struct {
 	db *pgxpool.Pool
@@ -28,144 +81,136 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	}
 }
 
-// GetByAppleID retrieves a user by their Apple ID
-func (r *UserRepository) GetByAppleID(ctx context.Context, appleID string) (*model.User, error) {
+// GetByID retrieves a user by their ID
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
 	// Create context with timeout to prevent hanging queries
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
 	query := `
-		SELECT id, apple_id, google_id, email, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE apple_id = $1
+		WHERE id = $1
 	`
 
-	var user model.User
-	err := r.db.QueryRow(ctx, query, appleID).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
 
 	if err == pgx.ErrNoRows {
 		return nil, nil // User not found
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by apple_id: %w", err)
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// GetByGoogleID retrieves a user by their Google ID
-func (r *UserRepository) GetByGoogleID(ctx context.Context, googleID string) (*model.User, error) {
+// GetByEmail retrieves a user by their email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	// Create context with timeout to prevent hanging queries
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
 	query := `
-		SELECT id, apple_id, google_id, email, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE google_id = $1
+		WHERE email = $1
 	`
 
-	var user model.User
-	err := r.db.QueryRow(ctx, query, googleID).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRow(ctx, query, email))
 
 	if err == pgx.ErrNoRows {
 		return nil, nil // User not found
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by google_id: %w", err)
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// GetByEmail retrieves a user by their email address
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+// Delete permanently deletes a user
+// Refresh tokens and other per-user rows are removed by ON DELETE CASCADE
+// Returns false if the user did not exist
+func (r *UserRepository) Delete(ctx context.Context, id int64) (bool, error) {
 	// Create context with timeout to prevent hanging queries
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	query := `
-		SELECT id, apple_id, google_id, email, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-
-	var user model.User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	query := `DELETE FROM users WHERE id = $1`
 
-	if err == pgx.ErrNoRows {
-		return nil, nil // User not found
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	return result.RowsAffected() > 0, nil
+}
+
+// SetEmailDeliverable records whether mail sent to the user's email address is delivered
+// Used for Apple private relay addresses whose forwarding the user can turn off
+func (r *UserRepository) SetEmailDeliverable(ctx context.Context, id int64, deliverable bool) error {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE users
+		SET email_deliverable = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Exec(ctx, query, id, deliverable); err != nil {
+		return fmt.Errorf("failed to set email deliverability: %w", err)
 	}
 
-	return &user, nil
+	return nil
 }
 
-// Create creates a new user in the database
-func (r *UserRepository) Create(ctx context.Context, appleID, email string) (*model.User, error) {
+// GetByIdentity retrieves the user linked to a provider identity
+func (r *UserRepository) GetByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
 	// Create context with timeout to prevent hanging queries
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
 	query := `
-		INSERT INTO users (apple_id, email)
-		VALUES ($1, $2)
-		RETURNING id, apple_id, google_id, email, created_at, updated_at
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = (
+			SELECT user_id FROM user_identities
+			WHERE provider = $1 AND subject = $2
+		)
 	`
 
-	var user model.User
-	err := r.db.QueryRow(ctx, query, appleID, email).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRow(ctx, query, provider, subject))
+
+	if err == pgx.ErrNoRows {
+		return nil, nil // User not found
+	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// CreateOrGet creates a new user or returns existing user (atomic upsert with account linking)
-// If a user with the same email exists, links the Apple ID to that account
-// This prevents duplicate accounts when users sign in with different providers
-func (r *UserRepository) CreateOrGet(ctx context.Context, appleID, email string) (*model.User, error) {
+// CreateWithIdentity creates a new user holding a single provider identity
+// Returns ErrEmailTaken if another user already has the identity's email;
+// linking to that user is a policy decision left to the caller
+func (r *UserRepository) CreateWithIdentity(ctx context.Context, identity *model.Identity) (*model.User, error) {
 	// Validate input
-	if err := validator.ValidateAppleID(appleID); err != nil {
-		return nil, fmt.Errorf("invalid apple_id: %w", err)
+	if err := validator.ValidateProvider(identity.Provider); err != nil {
+		return nil, fmt.Errorf("invalid provider: %w", err)
 	}
 
-	if err := validator.ValidateEmail(email); err != nil {
+	if err := validator.ValidateSubject(identity.Subject); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	if err := validator.ValidateEmail(identity.Email); err != nil {
 		return nil, fmt.Errorf("invalid email: %w", err)
 	}
 
@@ -173,77 +218,194 @@ func (r *UserRepository) CreateOrGet(ctx context.Context, appleID, email string)
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	// Use PostgreSQL UPSERT with email conflict handling for account linking
-	// Priority: email > apple_id (email takes precedence for linking accounts)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	user, err := scanUser(tx.QueryRow(ctx, `
+		INSERT INTO users (email)
+		VALUES ($1)
+		ON CONFLICT (email) DO NOTHING
+		RETURNING `+userColumns,
+		identity.Email,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+	`, user.ID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateProfile applies a profile edit and returns the updated user
+// Nil fields are left unchanged and empty strings clear a field; returns nil if the user does not exist
+func (r *UserRepository) UpdateProfile(ctx context.Context, id int64, update *model.UpdateProfileRequest) (*model.User, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO users (apple_id, email)
-		VALUES ($1, $2)
-		ON CONFLICT (email)
-		DO UPDATE SET
-			apple_id = COALESCE(users.apple_id, EXCLUDED.apple_id),
+		UPDATE users SET
+			display_name = CASE WHEN $2 THEN NULLIF($3, '') ELSE display_name END,
+			avatar_url = CASE WHEN $4 THEN NULLIF($5, '') ELSE avatar_url END,
+			locale = CASE WHEN $6 THEN NULLIF($7, '') ELSE locale END,
 			updated_at = CURRENT_TIMESTAMP
-		RETURNING id, apple_id, google_id, email, created_at, updated_at
-	`
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	displayName, setDisplayName := optionalString(update.DisplayName)
+	avatarURL, setAvatarURL := optionalString(update.AvatarURL)
+	locale, setLocale := optionalString(update.Locale)
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id,
+		setDisplayName, displayName,
+		setAvatarURL, avatarURL,
+		setLocale, locale,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
 
-	var user model.User
-	err := r.db.QueryRow(ctx, query, appleID, email).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return user, nil
+}
 
+// FillProfile sets the user's empty profile fields from a provider profile
+// Fields the user already has (possibly edited by them) are never overwritten.
+// Returns the updated user, or nil if no field changed.
+func (r *UserRepository) FillProfile(ctx context.Context, id int64, profile model.Profile) (*model.User, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE users SET
+			display_name = COALESCE(display_name, NULLIF($2, '')),
+			avatar_url = COALESCE(avatar_url, NULLIF($3, '')),
+			locale = COALESCE(locale, NULLIF($4, '')),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (
+			(display_name IS NULL AND $2 <> '') OR
+			(avatar_url IS NULL AND $3 <> '') OR
+			(locale IS NULL AND $4 <> '')
+		)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id, profile.DisplayName, profile.AvatarURL, profile.Locale))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create or update user: %w", err)
+		return nil, fmt.Errorf("failed to fill profile: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// CreateOrGetWithGoogle creates a new user or returns existing user for Google auth (atomic upsert with account linking)
-// If a user with the same email exists, links the Google ID to that account
-// This prevents duplicate accounts when users sign in with different providers
-func (r *UserRepository) CreateOrGetWithGoogle(ctx context.Context, googleID, email string) (*model.User, error) {
-	// Validate input
-	if err := validator.ValidateGoogleID(googleID); err != nil {
-		return nil, fmt.Errorf("invalid google_id: %w", err)
+// optionalString splits an optional field into its value and whether it is set
+func optionalString(value *string) (string, bool) {
+	if value == nil {
+		return "", false
 	}
+	return *value, true
+}
 
-	if err := validator.ValidateEmail(email); err != nil {
-		return nil, fmt.Errorf("invalid email: %w", err)
-	}
+// likeEscaper escapes the LIKE wildcards of a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
+// Search retrieves up to limit users matching the request's filters with IDs above its cursor, by ID
+// Empty filters match every user; subject is only matched together with provider
+func (r *UserRepository) Search(ctx context.Context, req *model.UserSearchRequest, limit int) ([]model.User, error) {
 	// Create context with timeout to prevent hanging queries
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	// Use PostgreSQL UPSERT with email conflict handling for account linking
-	// Priority: email > google_id (email takes precedence for linking accounts)
 	query := `
-		INSERT INTO users (google_id, email)
-		VALUES ($1, $2)
-		ON CONFLICT (email)
-		DO UPDATE SET
-			google_id = COALESCE(users.google_id, EXCLUDED.google_id),
-			updated_at = CURRENT_TIMESTAMP
-		RETURNING id, apple_id, google_id, email, created_at, updated_at
+		SELECT ` + userColumns + `
+		FROM users u
+		WHERE ($1::text = '' OR LOWER(u.email) LIKE $1 || '%')
+		  AND ($2::text = '' OR EXISTS (
+			SELECT 1 FROM user_identities i
+			WHERE i.user_id = u.id AND i.provider = $2 AND ($3::text = '' OR i.subject = $3)
+		  ))
+		  AND u.id > $4
+		ORDER BY u.id
+		LIMIT $5
 	`
 
-	var user model.User
-	err := r.db.QueryRow(ctx, query, googleID, email).Scan(
-		&user.ID,
-		&user.AppleID,
-		&user.GoogleID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	emailPrefix := likeEscaper.Replace(strings.ToLower(strings.TrimSpace(req.Email)))
 
+	rows, err := r.db.Query(ctx, query, emailPrefix, req.Provider, req.Subject, req.Cursor, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create or update user with google: %w", err)
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
 	}
 
-	return &user, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetStatus sets a user's account status
+// expiresAt, if set, is when the status reverts to active. Returns nil if the user does not exist.
+func (r *UserRepository) SetStatus(ctx context.Context, id int64, status model.UserStatus, reason string, expiresAt *time.Time) (*model.User, error) {
+	// Create context with timeout to prevent hanging queries
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	// status_expires_at is a TIMESTAMP column, so pass UTC: pgx drops the offset of other zones
+	var expiresAtUTC *time.Time
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAtUTC = &utc
+	}
+
+	query := `
+		UPDATE users
+		SET status = $2,
+		    status_reason = NULLIF($3, ''),
+		    status_expires_at = $4,
+		    status_changed_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id, string(status), reason, expiresAtUTC))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set user status: %w", err)
+	}
+
+	return user, nil
 }
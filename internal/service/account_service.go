@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+)
+
+// ErrUserNotFound is returned when the user referenced by a token no longer exists
+var ErrUserNotFound = errors.New("user not found")
+
+// AccountService handles account lifecycle business logic
+type AccountService struct {
+	userRepository repository.UserStore
+	sessionService *SessionService
+	appleTokens    *AppleTokenService
+}
+
+// NewAccountService creates a new account service
+// appleTokens may be nil when Apple token exchange is not configured
+func NewAccountService(
+	userRepo repository.UserStore,
+	sessionService *SessionService,
+	appleTokens *AppleTokenService,
+) *AccountService {
+	return &AccountService{
+		userRepository: userRepo,
+		sessionService: sessionService,
+		appleTokens:    appleTokens,
+	}
+}
+
+// DeleteAccount permanently deletes the current user's account
+// Revokes Apple tokens, ends every session, deletes the user row and purges cached copies
+func (s *AccountService) DeleteAccount(ctx context.Context, claims *jwt.TokenClaims) error {
+	user, err := s.userRepository.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	// Apple requires apps to revoke Sign in with Apple tokens on account deletion.
+	// This must happen before the user row (and the stored token with it) is deleted.
+	s.revokeAppleTokens(ctx, user.ID)
+
+	// End every session and invalidate outstanding access tokens
+	if err := s.sessionService.LogoutAll(ctx, claims); err != nil {
+		return fmt.Errorf("failed to end sessions: %w", err)
+	}
+
+	return s.deleteUser(ctx, user.ID)
+}
+
+// DeleteUser permanently deletes a user on behalf of the system rather than the user
+// Used when an identity provider reports that the user's account was deleted
+func (s *AccountService) DeleteUser(ctx context.Context, userID int64) error {
+	user, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	s.revokeAppleTokens(ctx, user.ID)
+
+	if err := s.sessionService.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to end sessions: %w", err)
+	}
+
+	return s.deleteUser(ctx, user.ID)
+}
+
+// deleteUser deletes the user row; the user store purges cached copies
+// Sessions must already have been ended
+func (s *AccountService) deleteUser(ctx context.Context, userID int64) error {
+	if _, err := s.userRepository.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
+
+// revokeAppleTokens revokes the user's Apple refresh token, if one is stored
+// Failures are logged rather than returned so Apple outages cannot block account deletion
+func (s *AccountService) revokeAppleTokens(ctx context.Context, userID int64) {
+	// Without token exchange no Apple refresh tokens are ever stored
+	if s.appleTokens == nil {
+		return
+	}
+
+	if err := s.appleTokens.RevokeTokens(ctx, userID); err != nil {
+		log.Printf("Failed to revoke Apple tokens for user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidSearch is returned when a user search combines filters incorrectly
+	ErrInvalidSearch = errors.New("invalid search")
+
+	// ErrSelfSuspension is returned when an administrator tries to restrict their own account
+	ErrSelfSuspension = errors.New("cannot suspend yourself")
+
+	// ErrInvalidStatus is returned when a status change is invalid
+	ErrInvalidStatus = errors.New("invalid status")
+
+	// ErrKeyRotationDisabled is returned when rotating signing keys without a database key ring
+	ErrKeyRotationDisabled = errors.New("signing key rotation is not enabled")
+)
+
+// defaultSearchLimit is the page size of user searches that do not set one
+const defaultSearchLimit = 20
+
+// AdminService implements the support and operations endpoints of the admin API
+type AdminService struct {
+	userRepository     repository.UserStore
+	identityRepository *repository.IdentityRepository
+	tokenRepository    *repository.TokenRepository
+	roleRepository     *repository.RoleRepository
+	sessionService     *SessionService
+	keyRotation        *KeyRotationService
+}
+
+// NewAdminService creates a new admin service
+func NewAdminService(
+	userRepo repository.UserStore,
+	identityRepo *repository.IdentityRepository,
+	tokenRepo *repository.TokenRepository,
+	roleRepo *repository.RoleRepository,
+	sessionService *SessionService,
+) *AdminService {
+	return &AdminService{
+		userRepository:     userRepo,
+		identityRepository: identityRepo,
+		tokenRepository:    tokenRepo,
+		roleRepository:     roleRepo,
+		sessionService:     sessionService,
+	}
+}
+
+// WithKeyRotation enables rotating signing keys on demand
+func (s *AdminService) WithKeyRotation(keyRotation *KeyRotationService) *AdminService {
+	s.keyRotation = keyRotation
+	return s
+}
+
+// SearchUsers returns a page of users matching the request's filters
+func (s *AdminService) SearchUsers(ctx context.Context, req *model.UserSearchRequest) (*model.UserSearchResponse, error) {
+	if req.Subject != "" && req.Provider == "" {
+		return nil, fmt.Errorf("%w: subject requires provider", ErrInvalidSearch)
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	// Fetch one extra user to tell whether another page follows
+	users, err := s.userRepository.Search(ctx, req, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &model.UserSearchResponse{Users: users}
+	if len(users) > limit {
+		response.Users = users[:limit]
+		response.NextCursor = users[limit-1].ID
+	}
+
+	return response, nil
+}
+
+// GetUser returns a user with their linked logins, active sessions and roles
+func (s *AdminService) GetUser(ctx context.Context, userID int64) (*model.AdminUserResponse, error) {
+	user, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	identities, err := s.identityRepository.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.tokenRepository.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepository.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AdminUserResponse{
+		User:       user,
+		Identities: identities,
+		Sessions:   sessions,
+		Roles:      roles,
+	}, nil
+}
+
+// SetUserStatus sets a user's account status
+// Any status other than active blocks sign-in, token refresh and API access and ends every session
+func (s *AdminService) SetUserStatus(ctx context.Context, claims *jwt.TokenClaims, userID int64, req *model.SetUserStatusRequest) (*model.User, error) {
+	if req.Status == model.UserStatusActive {
+		// Reason and expiry only describe restrictions
+		req.Reason = ""
+		req.ExpiresAt = nil
+	} else if userID == claims.UserID {
+		return nil, ErrSelfSuspension
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidStatus)
+	}
+
+	user, err := s.userRepository.SetStatus(ctx, userID, req.Status, req.Reason, req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	logger.SecurityEvent("user_status_changed",
+		zap.Int64("user_id", userID),
+		zap.String("status", string(req.Status)),
+		zap.String("reason", req.Reason),
+		zap.Timep("expires_at", req.ExpiresAt),
+		zap.Int64("actor_id", claims.UserID),
+	)
+
+	if req.Status != model.UserStatusActive {
+		if err := s.sessionService.RevokeAllUserSessions(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// SuspendUser suspends a user until req.ExpiresAt, or until unsuspended
+func (s *AdminService) SuspendUser(ctx context.Context, claims *jwt.TokenClaims, userID int64, req *model.SuspendUserRequest) (*model.User, error) {
+	return s.SetUserStatus(ctx, claims, userID, &model.SetUserStatusRequest{
+		Status:    model.UserStatusSuspended,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	})
+}
+
+// UnsuspendUser restores a user's access by setting their status to active
+func (s *AdminService) UnsuspendUser(ctx context.Context, claims *jwt.TokenClaims, userID int64) (*model.User, error) {
+	return s.SetUserStatus(ctx, claims, userID, &model.SetUserStatusRequest{Status: model.UserStatusActive})
+}
+
+// ForceLogout ends every session of a user
+// Refresh tokens are revoked in the database and access tokens through the blacklist
+func (s *AdminService) ForceLogout(ctx context.Context, claims *jwt.TokenClaims, userID int64) error {
+	user, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	logger.SecurityEvent("user_force_logout",
+		zap.Int64("user_id", userID),
+		zap.Int64("actor_id", claims.UserID),
+	)
+
+	return s.sessionService.RevokeAllUserSessions(ctx, userID)
+}
+
+// RotateSigningKey adds a new signing key to the key ring
+// The key is published immediately and starts signing after the publish delay
+func (s *AdminService) RotateSigningKey(ctx context.Context, claims *jwt.TokenClaims) (*model.SigningKey, error) {
+	if s.keyRotation == nil {
+		return nil, ErrKeyRotationDisabled
+	}
+
+	// The rotation itself is logged by the key rotation service
+	logger.SecurityEvent("signing_key_rotation_requested", zap.Int64("actor_id", claims.UserID))
+
+	return s.keyRotation.Rotate(ctx)
+}
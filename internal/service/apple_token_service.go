@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/pkg/apple"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// appleValidationCheckInterval is how often the account validation job looks for due tokens
+	appleValidationCheckInterval = 10 * time.Minute
+
+	// appleValidationBatchSize is how many due tokens are loaded at a time
+	appleValidationBatchSize = 100
+)
+
+// AppleTokenStore persists the Apple refresh tokens obtained for users
+type AppleTokenStore interface {
+	StoreRefreshToken(ctx context.Context, userID int64, refreshToken string) error
+
+	// GetRefreshToken returns the user's Apple refresh token, or "" if none is stored
+	GetRefreshToken(ctx context.Context, userID int64) (string, error)
+
+	MarkValidated(ctx context.Context, userID int64) error
+	DeleteRefreshToken(ctx context.Context, userID int64) error
+
+	// ListUnvalidatedSince returns up to limit users after afterUserID, by ID, whose token
+	// was last validated before validatedBefore
+	ListUnvalidatedSince(ctx context.Context, validatedBefore time.Time, afterUserID int64, limit int) ([]int64, error)
+
+	// WithValidationLock runs fn unless another instance is validating tokens, reporting whether it ran
+	WithValidationLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+}
+
+// AppleTokenService manages the Apple refresh tokens obtained via authorization code exchange
+type AppleTokenService struct {
+	client      apple.TokenClient
+	store       AppleTokenStore
+	redirectURI string
+
+	// Periodic account validation (see WithAccountValidation)
+	sessionService     *SessionService
+	validationInterval time.Duration
+}
+
+// NewAppleTokenService creates a new Apple token service
+// redirectURI is only needed for web flows; native apps leave it empty
+func NewAppleTokenService(client apple.TokenClient, store AppleTokenStore, redirectURI string) *AppleTokenService {
+	return &AppleTokenService{
+		client:      client,
+		store:       store,
+		redirectURI: redirectURI,
+	}
+}
+
+// RedeemAuthorizationCode exchanges the code returned at sign-in and stores the Apple refresh token
+// appleID is the verified subject of the sign-in ID token; the code must belong to the same user
+func (s *AppleTokenService) RedeemAuthorizationCode(ctx context.Context, userID int64, appleID, code string) error {
+	response, err := s.client.ExchangeCode(ctx, code, s.redirectURI)
+	if err != nil {
+		return fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	subject, err := response.IDTokenSubject()
+	if err != nil {
+		return fmt.Errorf("failed to read exchanged id_token: %w", err)
+	}
+	if subject != appleID {
+		return fmt.Errorf("authorization code belongs to a different apple user")
+	}
+
+	if err := s.store.StoreRefreshToken(ctx, userID, response.RefreshToken); err != nil {
+		return fmt.Errorf("failed to store apple refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// WithAccountValidation makes Run check every stored token with Apple once per interval
+// and end the sessions of users who no longer authorize this app
+func (s *AppleTokenService) WithAccountValidation(sessionService *SessionService, interval time.Duration) *AppleTokenService {
+	s.sessionService = sessionService
+	s.validationInterval = interval
+	return s
+}
+
+// Run validates the accounts of users whose token is due for validation until ctx is done
+// One instance at a time validates; the others skip the round
+func (s *AppleTokenService) Run(ctx context.Context) {
+	ticker := time.NewTicker(appleValidationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.store.WithValidationLock(ctx, s.validateDueAccounts); err != nil {
+				log.Printf("Failed to validate Apple accounts: %v", err)
+			}
+		}
+	}
+}
+
+// validateDueAccounts validates every token not validated within the validation interval
+// Tokens that fail to validate stay due and are retried in the next round
+func (s *AppleTokenService) validateDueAccounts(ctx context.Context) error {
+	validatedBefore := time.Now().Add(-s.validationInterval)
+
+	var afterUserID int64
+	for {
+		userIDs, err := s.store.ListUnvalidatedSince(ctx, validatedBefore, afterUserID, appleValidationBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.validateAccount(ctx, userID)
+		}
+
+		if len(userIDs) < appleValidationBatchSize {
+			return nil
+		}
+		afterUserID = userIDs[len(userIDs)-1]
+	}
+}
+
+// validateAccount validates one user's account, ending their sessions if Apple rejects the token
+func (s *AppleTokenService) validateAccount(ctx context.Context, userID int64) {
+	valid, err := s.ValidateAccount(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to validate Apple account of user %d: %v", userID, err)
+		return
+	}
+	if valid {
+		return
+	}
+
+	// The user revoked this app's authorization without a consent-revoked notification reaching us
+	logger.SecurityEvent("apple_authorization_invalid", zap.Int64("user_id", userID))
+	if err := s.sessionService.RevokeAllUserSessions(ctx, userID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+}
+
+// ValidateAccount checks with Apple that the user still authorizes this app
+// Returns false if Apple rejects the stored refresh token; the token is then discarded.
+// Users without a stored token are reported as valid since there is nothing to check.
+func (s *AppleTokenService) ValidateAccount(ctx context.Context, userID int64) (bool, error) {
+	refreshToken, err := s.store.GetRefreshToken(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load apple refresh token: %w", err)
+	}
+	if refreshToken == "" {
+		return true, nil
+	}
+
+	_, err = s.client.RefreshToken(ctx, refreshToken)
+	if errors.Is(err, apple.ErrInvalidGrant) {
+		if err := s.store.DeleteRefreshToken(ctx, userID); err != nil {
+			return false, fmt.Errorf("failed to delete rejected apple refresh token: %w", err)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to validate apple refresh token: %w", err)
+	}
+
+	if err := s.store.MarkValidated(ctx, userID); err != nil {
+		return false, fmt.Errorf("failed to record apple token validation: %w", err)
+	}
+
+	return true, nil
+}
+
+// RevokeTokens revokes the user's stored Apple refresh token with Apple
+// Does nothing if no token is stored
+func (s *AppleTokenService) RevokeTokens(ctx context.Context, userID int64) error {
+	refreshToken, err := s.store.GetRefreshToken(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load apple refresh token: %w", err)
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	if err := s.client.RevokeToken(ctx, refreshToken, apple.TokenTypeRefreshToken); err != nil {
+		return fmt.Errorf("failed to revoke apple refresh token: %w", err)
+	}
+
+	if err := s.store.DeleteRefreshToken(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete apple refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// DiscardTokens deletes the user's stored Apple refresh token without contacting Apple
+// Used when Apple reports that the token is no longer valid
+func (s *AppleTokenService) DiscardTokens(ctx context.Context, userID int64) error {
+	if err := s.store.DeleteRefreshToken(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete apple refresh token: %w", err)
+	}
+	return nil
+}
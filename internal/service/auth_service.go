@@ -2,76 +2,228 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/Hamid207/ai-code-test1/internal/model"
 	"github.com/Hamid207/ai-code-test1/internal/repository"
 	"github.com/Hamid207/ai-code-test1/pkg/apple"
 	"github.com/Hamid207/ai-code-test1/pkg/google"
 	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
 )
 
+// ErrUnknownProvider is returned when sign-in is attempted with a provider that is not configured
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// ErrProviderDisabled is returned when the identity provider reported the user's account as disabled
+var ErrProviderDisabled = errors.New("identity provider account disabled")
+
+// ErrAccountSuspended is matched by AccountSuspendedError with errors.Is
+var ErrAccountSuspended = errors.New("account suspended")
+
+// ErrLinkRequired is matched by LinkRequiredError with errors.Is
+var ErrLinkRequired = errors.New("account linking required")
+
+// ErrInvalidLinkTicket is returned when a link ticket is unknown, expired or issued for another user
+var ErrInvalidLinkTicket = errors.New("invalid or expired link ticket")
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, revoked, expired or already rotated
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// linkTicketTTL is how long a link_required ticket can be confirmed
+const linkTicketTTL = 10 * time.Minute
+
+// refreshTokenCacheTimeout bounds Redis calls on the refresh path
+// Redis is only a fast path, so an unresponsive Redis must not hold up refreshes
+const refreshTokenCacheTimeout = 200 * time.Millisecond
+
+// LinkRequiredError is returned when a new login's email belongs to an existing user
+// and the linking policy does not allow linking it automatically.
+// The login is linked once the user signs in with one of Providers presenting Ticket.
+type LinkRequiredError struct {
+	Ticket    string
+	ExpiresAt time.Time
+	Providers []string
+}
+
+// Error implements error
+func (e *LinkRequiredError) Error() string {
+	return ErrLinkRequired.Error()
+}
+
+// Is reports whether target is ErrLinkRequired
+func (e *LinkRequiredError) Is(target error) bool {
+	return target == ErrLinkRequired
+}
+
+// AccountSuspendedError is returned when a user whose status blocks access signs in or refreshes tokens
+type AccountSuspendedError struct {
+	Status    model.UserStatus
+	ExpiresAt *time.Time // When the status reverts to active, if it does
+}
+
+// Error implements error
+func (e *AccountSuspendedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAccountSuspended.Error(), e.Status)
+}
+
+// Is reports whether target is ErrAccountSuspended
+func (e *AccountSuspendedError) Is(target error) bool {
+	return target == ErrAccountSuspended
+}
+
+// checkUserStatus returns an *AccountSuspendedError unless the user's status is active
+func checkUserStatus(user *model.User) error {
+	status := user.EffectiveStatus(time.Now())
+	if status == model.UserStatusActive {
+		return nil
+	}
+	return &AccountSuspendedError{Status: status, ExpiresAt: user.StatusExpiresAt}
+}
+
+// AuthorizationStore looks up the roles of users; implemented by repository.RoleRepository
+type AuthorizationStore interface {
+	// GetAuthorization returns a user's roles and the permissions they grant
+	GetAuthorization(ctx context.Context, userID int64) (*model.Authorization, error)
+}
+
 // AuthService handles authentication business logic
 type AuthService struct {
-	appleVerifier   *apple.Verifier
-	googleVerifier  *google.Verifier
-	userRepository  *repository.UserRepository
-	tokenRepository *repository.TokenRepository
-	tokenService    *jwt.TokenService
+	appleVerifier      *apple.Verifier
+	googleVerifier     *google.Verifier
+	userRepository     repository.UserStore
+	identityRepository *repository.IdentityRepository
+	tokenRepository    RefreshTokenStore
+	roleRepository     AuthorizationStore
+	tokenService       *jwt.TokenService
+	sessionService     *SessionService
+	linkTickets        repository.RedisLinkTicketRepository
+	linkingPolicy      *LinkingPolicy
+	profileService     *ProfileService
+	appleTokens        *AppleTokenService
+	redisTokens        repository.RedisTokenRepository
+	lockout            *LockoutService
+	providers          map[string]IdentityProvider
 }
 
 // NewAuthService creates a new authentication service
+// The verifiers are shared with other services so their public key caches are reused
 func NewAuthService(
-	appleClientID string,
-	googleClientID string,
-	userRepo *repository.UserRepository,
-	tokenRepo *repository.TokenRepository,
+	appleVerifier *apple.Verifier,
+	googleVerifier *google.Verifier,
+	userRepo repository.UserStore,
+	identityRepo *repository.IdentityRepository,
+	tokenRepo RefreshTokenStore,
+	roleRepo AuthorizationStore,
 	tokenService *jwt.TokenService,
+	sessionService *SessionService,
+	linkTickets repository.RedisLinkTicketRepository,
+	linkingPolicy *LinkingPolicy,
+	profileService *ProfileService,
 ) *AuthService {
 	return &AuthService{
-		appleVerifier:   apple.NewVerifier(appleClientID),
-		googleVerifier:  google.NewVerifier(googleClientID),
-		userRepository:  userRepo,
-		tokenRepository: tokenRepo,
-		tokenService:    tokenService,
+		appleVerifier:      appleVerifier,
+		googleVerifier:     googleVerifier,
+		userRepository:     userRepo,
+		identityRepository: identityRepo,
+		tokenRepository:    tokenRepo,
+		roleRepository:     roleRepo,
+		tokenService:       tokenService,
+		sessionService:     sessionService,
+		linkTickets:        linkTickets,
+		linkingPolicy:      linkingPolicy,
+		profileService:     profileService,
+		providers:          make(map[string]IdentityProvider),
+	}
+}
+
+// WithAppleTokens enables redeeming Apple authorization codes at sign-in
+func (s *AuthService) WithAppleTokens(appleTokens *AppleTokenService) *AuthService {
+	s.appleTokens = appleTokens
+	return s
+}
+
+// WithRedisTokens enables the Redis fast path for refresh token rotation
+// Postgres remains the source of truth; Redis only saves the lookup of active tokens
+func (s *AuthService) WithRedisTokens(redisTokens repository.RedisTokenRepository) *AuthService {
+	s.redisTokens = redisTokens
+	return s
+}
+
+// WithLockout enables lockouts after repeated failed sign-ins and refreshes
+func (s *AuthService) WithLockout(lockout *LockoutService) *AuthService {
+	s.lockout = lockout
+	return s
+}
+
+// WithIdentityProviders enables sign-in with generic identity providers
+func (s *AuthService) WithIdentityProviders(providers ...IdentityProvider) *AuthService {
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
 	}
+	return s
 }
 
 // SignInWithApple verifies Apple ID token and returns user information with JWT tokens
-func (s *AuthService) SignInWithApple(ctx context.Context, req *model.AppleSignInRequest) (*model.AppleSignInResponse, error) {
+func (s *AuthService) SignInWithApple(ctx context.Context, req *model.AppleSignInRequest, client model.ClientInfo) (*model.AppleSignInResponse, error) {
+	ipKey := ipLockoutKey(client.IPAddress)
+	if err := s.lockout.check(ctx, client, true, ipKey); err != nil {
+		return nil, err
+	}
+
 	// Verify the ID token
 	claims, err := s.appleVerifier.VerifyIDToken(req.IDToken, req.Nonce)
 	if err != nil {
+		s.lockout.recordFailure(ctx, ipKey)
 		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 
+	// Only verified subjects are counted, so forged tokens cannot lock a user out
+	subjectKey := subjectLockoutKey(model.ProviderApple, claims.Subject)
+	if err := s.lockout.check(ctx, client, false, subjectKey); err != nil {
+		return nil, err
+	}
+
 	// Verify email is confirmed (security best practice)
 	if claims.EmailVerified != "true" {
+		s.lockout.recordFailure(ctx, ipKey, subjectKey)
 		return nil, fmt.Errorf("email not verified by Apple")
 	}
 
-	// Create or get user from database
-	user, err := s.userRepository.CreateOrGet(ctx, claims.Subject, claims.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create or get user: %w", err)
+	identity := &model.Identity{
+		Provider:      model.ProviderApple,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: true,
+		Profile:       appleProfile(req.User),
 	}
 
-	// Generate JWT token pair (access + refresh)
-	tokenPair, err := s.tokenService.GenerateTokenPair(user.ID, user.AppleID, user.Email)
+	user, tokenPair, err := s.signIn(ctx, identity, req.LinkTicket, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, err
 	}
+	s.lockout.recordSuccess(ctx, subjectKey)
 
-	// Store refresh token in database
-	err = s.tokenRepository.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, tokenPair.RefreshTokenExpiresAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	// Obtain an Apple refresh token (needed for revocation on account deletion)
+	// Sign-in does not depend on it, so failures are only logged
+	if req.AuthorizationCode != "" && s.appleTokens != nil {
+		if err := s.appleTokens.RedeemAuthorizationCode(ctx, user.ID, claims.Subject, req.AuthorizationCode); err != nil {
+			log.Printf("Failed to redeem Apple authorization code for user %d: %v", user.ID, err)
+		}
 	}
 
 	// Build response with tokens
 	response := &model.AppleSignInResponse{
 		UserID:                user.ID,
-		AppleID:               user.AppleID,
+		AppleID:               identity.Subject,
 		Email:                 user.Email,
 		AccessToken:           tokenPair.AccessToken,
 		RefreshToken:          tokenPair.RefreshToken,
@@ -84,38 +236,97 @@ func (s *AuthService) SignInWithApple(ctx context.Context, req *model.AppleSignI
 }
 
 // SignInWithGoogle verifies Google ID token and returns user information with JWT tokens
-func (s *AuthService) SignInWithGoogle(ctx context.Context, req *model.GoogleSignInRequest) (*model.GoogleSignInResponse, error) {
+func (s *AuthService) SignInWithGoogle(ctx context.Context, req *model.GoogleSignInRequest, client model.ClientInfo) (*model.GoogleSignInResponse, error) {
+	ipKey := ipLockoutKey(client.IPAddress)
+	if err := s.lockout.check(ctx, client, true, ipKey); err != nil {
+		return nil, err
+	}
+
 	// Verify the ID token
 	claims, err := s.googleVerifier.VerifyIDToken(req.IDToken)
 	if err != nil {
+		s.lockout.recordFailure(ctx, ipKey)
 		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 
+	// Only verified subjects are counted, so forged tokens cannot lock a user out
+	subjectKey := subjectLockoutKey(model.ProviderGoogle, claims.Subject)
+	if err := s.lockout.check(ctx, client, false, subjectKey); err != nil {
+		return nil, err
+	}
+
 	// Email is already verified in the verifier (EmailVerified must be true)
+	identity := &model.Identity{
+		Provider:      model.ProviderGoogle,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Profile:       googleProfile(claims),
+	}
 
-	// Create or get user from database
-	user, err := s.userRepository.CreateOrGetWithGoogle(ctx, claims.Subject, claims.Email)
+	user, tokenPair, err := s.signIn(ctx, identity, req.LinkTicket, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create or get user: %w", err)
+		return nil, err
 	}
+	s.lockout.recordSuccess(ctx, subjectKey)
 
-	// Generate JWT token pair (access + refresh)
-	// Use GoogleID as the provider ID (AppleID field in JWT for backward compatibility)
-	tokenPair, err := s.tokenService.GenerateTokenPair(user.ID, user.GoogleID, user.Email)
+	// Build response with tokens
+	response := &model.GoogleSignInResponse{
+		UserID:                user.ID,
+		GoogleID:              identity.Subject,
+		Email:                 user.Email,
+		AccessToken:           tokenPair.AccessToken,
+		RefreshToken:          tokenPair.RefreshToken,
+		AccessTokenExpiresAt:  tokenPair.AccessTokenExpiresAt,
+		RefreshTokenExpiresAt: tokenPair.RefreshTokenExpiresAt,
+		TokenType:             "Bearer",
+	}
+
+	return response, nil
+}
+
+// SignInWithProvider verifies an ID token of a generic identity provider and returns JWT tokens
+func (s *AuthService) SignInWithProvider(ctx context.Context, providerName string, req *model.ProviderSignInRequest, client model.ClientInfo) (*model.ProviderSignInResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	ipKey := ipLockoutKey(client.IPAddress)
+	if err := s.lockout.check(ctx, client, true, ipKey); err != nil {
+		return nil, err
+	}
+
+	// Verify the ID token
+	identity, err := provider.VerifyIDToken(ctx, req.IDToken, req.Nonce)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		s.lockout.recordFailure(ctx, ipKey)
+		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 
-	// Store refresh token in database
-	err = s.tokenRepository.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, tokenPair.RefreshTokenExpiresAt)
+	// Only verified subjects are counted, so forged tokens cannot lock a user out
+	subjectKey := subjectLockoutKey(providerName, identity.Subject)
+	if err := s.lockout.check(ctx, client, false, subjectKey); err != nil {
+		return nil, err
+	}
+
+	// Accounts are keyed by email, so it must be present and confirmed by the provider
+	if identity.Email == "" || !identity.EmailVerified {
+		s.lockout.recordFailure(ctx, ipKey, subjectKey)
+		return nil, fmt.Errorf("email not verified by %s", providerName)
+	}
+
+	user, tokenPair, err := s.signIn(ctx, identity, req.LinkTicket, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+		return nil, err
 	}
+	s.lockout.recordSuccess(ctx, subjectKey)
 
 	// Build response with tokens
-	response := &model.GoogleSignInResponse{
+	response := &model.ProviderSignInResponse{
 		UserID:                user.ID,
-		GoogleID:              user.GoogleID,
+		Provider:              providerName,
+		Subject:               identity.Subject,
 		Email:                 user.Email,
 		AccessToken:           tokenPair.AccessToken,
 		RefreshToken:          tokenPair.RefreshToken,
@@ -127,48 +338,308 @@ func (s *AuthService) SignInWithGoogle(ctx context.Context, req *model.GoogleSig
 	return response, nil
 }
 
+// signIn resolves the user of a verified identity and starts a new session
+// Returns ErrProviderDisabled if the provider reported the identity's account as disabled
+// and a *LinkRequiredError if the identity's email belongs to a user it may not be linked to automatically.
+// A non-empty linkTicket links the ticket's identity to the user, which requires an already linked identity.
+func (s *AuthService) signIn(ctx context.Context, identity *model.Identity, linkTicket string, client model.ClientInfo) (*model.User, *jwt.TokenPair, error) {
+	existing, err := s.identityRepository.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	var user *model.User
+	if existing != nil {
+		user, err = s.resolveLinkedIdentity(ctx, existing)
+	} else if linkTicket != "" {
+		// Tickets are confirmed by proving control of the existing user, not by a new login
+		err = ErrInvalidLinkTicket
+	} else {
+		user, err = s.resolveNewIdentity(ctx, identity)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkUserStatus(user); err != nil {
+		return nil, nil, err
+	}
+
+	if linkTicket != "" {
+		if err := s.confirmLinkTicket(ctx, user.ID, linkTicket); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Fill in profile fields the user has not set from what the provider asserted
+	user = s.profileService.ApplyProviderProfile(ctx, user, identity.Profile)
+
+	// Generate JWT token pair (access + refresh)
+	// Every sign-in starts a new refresh token family
+	familyID := jwt.NewFamilyID()
+	subject := jwt.Subject{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Provider: identity.Provider,
+
+		LegacySubject: identity.Subject,
+	}
+	if err := s.authorize(ctx, &subject); err != nil {
+		return nil, nil, err
+	}
+	// Unauthenticated client IDs get the default lifetimes
+	clientID := s.tokenService.AuthenticateClient(client.ClientID, client.ClientSecret)
+	tokenPair, err := s.tokenService.GenerateTokenPair(subject, familyID, clientID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	// Store refresh token in database
+	err = s.storeRefreshToken(ctx, user.ID, familyID, tokenPair, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return user, tokenPair, nil
+}
+
+// resolveLinkedIdentity returns the user of an identity that is already linked
+func (s *AuthService) resolveLinkedIdentity(ctx context.Context, identity *model.Identity) (*model.User, error) {
+	if identity.DisabledAt != nil {
+		return nil, ErrProviderDisabled
+	}
+
+	// last_used_at is informational, so sign-in does not fail on it
+	if err := s.identityRepository.MarkUsed(ctx, identity.ID); err != nil {
+		log.Printf("Failed to mark identity %d used: %v", identity.ID, err)
+	}
+
+	user, err := s.userRepository.GetByID(ctx, identity.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d not found", identity.UserID)
+	}
+
+	return user, nil
+}
+
+// resolveNewIdentity creates a user for an identity seen for the first time
+// If the identity's email already belongs to a user, the identity is linked to that user
+// only when the linking policy allows it; otherwise a link ticket is issued
+func (s *AuthService) resolveNewIdentity(ctx context.Context, identity *model.Identity) (*model.User, error) {
+	user, err := s.userRepository.CreateWithIdentity(ctx, identity)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrEmailTaken) {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	owner, err := s.userRepository.GetByEmail(ctx, identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if owner == nil {
+		// The owner was deleted in the meantime; the next attempt creates a new user
+		return nil, fmt.Errorf("user with email %s no longer exists", identity.Email)
+	}
+
+	linked, err := s.identityRepository.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	if !s.linkingPolicy.CanAutoLink(identity, linked) {
+		return nil, s.issueLinkTicket(ctx, owner.ID, identity, linked)
+	}
+
+	if err := s.linkIdentity(ctx, owner.ID, identity); err != nil {
+		return nil, err
+	}
+
+	logger.SecurityEvent("identity_auto_linked",
+		zap.Int64("user_id", owner.ID),
+		zap.String("provider", identity.Provider),
+	)
+
+	return owner, nil
+}
+
+// issueLinkTicket stores a pending link of identity to a user and returns the LinkRequiredError describing it
+func (s *AuthService) issueLinkTicket(ctx context.Context, userID int64, identity *model.Identity, linked []model.Identity) error {
+	ticketID, err := newLinkTicketID()
+	if err != nil {
+		return err
+	}
+
+	issuedAt := time.Now()
+	ticket := &model.LinkTicket{
+		UserID:   userID,
+		Identity: *identity,
+		IssuedAt: issuedAt,
+	}
+	if err := s.linkTickets.StoreLinkTicket(ctx, ticketID, ticket, linkTicketTTL); err != nil {
+		return fmt.Errorf("failed to store link ticket: %w", err)
+	}
+
+	// Only logins the user can currently sign in with can confirm the ticket
+	providers := make([]string, 0, len(linked))
+	for _, linkedIdentity := range linked {
+		if linkedIdentity.DisabledAt == nil {
+			providers = append(providers, linkedIdentity.Provider)
+		}
+	}
+
+	logger.SecurityEvent("identity_link_required",
+		zap.Int64("user_id", userID),
+		zap.String("provider", identity.Provider),
+	)
+
+	return &LinkRequiredError{
+		Ticket:    ticketID,
+		ExpiresAt: issuedAt.Add(linkTicketTTL),
+		Providers: providers,
+	}
+}
+
+// confirmLinkTicket links the identity of a link ticket to the signed-in user
+// Tickets are single-use: a ticket presented for another user is consumed and rejected
+func (s *AuthService) confirmLinkTicket(ctx context.Context, userID int64, ticketID string) error {
+	ticket, err := s.linkTickets.ConsumeLinkTicket(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to get link ticket: %w", err)
+	}
+	if ticket == nil || ticket.UserID != userID {
+		return ErrInvalidLinkTicket
+	}
+
+	if err := s.linkIdentity(ctx, userID, &ticket.Identity); err != nil {
+		return err
+	}
+
+	logger.SecurityEvent("identity_link_confirmed",
+		zap.Int64("user_id", userID),
+		zap.String("provider", ticket.Identity.Provider),
+	)
+
+	return nil
+}
+
+// linkIdentity links an identity to a user, mapping ownership conflicts to ErrIdentityConflict
+func (s *AuthService) linkIdentity(ctx context.Context, userID int64, identity *model.Identity) error {
+	_, err := s.identityRepository.Link(ctx, userID, identity)
+	if errors.Is(err, repository.ErrIdentityTaken) || errors.Is(err, repository.ErrProviderLinked) {
+		return fmt.Errorf("%w: %v", ErrIdentityConflict, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// newLinkTicketID generates a random, unguessable link ticket ID
+func newLinkTicketID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate link ticket: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // RefreshAccessToken generates new access AND refresh tokens (token rotation)
 // This implements refresh token rotation for security - old token is revoked
-func (s *AuthService) RefreshAccessToken(ctx context.Context, req *model.RefreshTokenRequest) (*model.RefreshTokenResponse, error) {
+// client describes the device presenting the token and becomes the session's latest location
+func (s *AuthService) RefreshAccessToken(ctx context.Context, req *model.RefreshTokenRequest, client model.ClientInfo) (*model.RefreshTokenResponse, error) {
+	lockoutKeys := []lockoutKey{ipLockoutKey(client.IPAddress)}
+	if err := s.lockout.check(ctx, client, false, lockoutKeys...); err != nil {
+		return nil, err
+	}
+
 	// Validate refresh token (JWT validation)
 	claims, err := s.tokenService.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
+		s.lockout.recordFailure(ctx, lockoutKeys...)
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
 
-	// Validate refresh token in database
-	userID, err := s.tokenRepository.ValidateRefreshToken(ctx, req.RefreshToken)
-	if err != nil {
-		return nil, fmt.Errorf("refresh token validation failed: %w", err)
+	// Failures of a validly signed token also count against its family (not set on version 1 tokens)
+	if claims.FamilyID != "" {
+		familyKey := familyLockoutKey(claims.FamilyID)
+		if err := s.lockout.check(ctx, client, false, familyKey); err != nil {
+			return nil, err
+		}
+		lockoutKeys = append(lockoutKeys, familyKey)
 	}
 
-	// Verify user ID matches
-	if userID != claims.UserID {
-		return nil, fmt.Errorf("user ID mismatch")
+	// Fast path: claim the token in Redis, skipping the Postgres lookup
+	// On a miss or outage Postgres is consulted, which also detects reuse of rotated tokens
+	storedToken := s.consumeCachedRefreshToken(ctx, claims, req.RefreshToken)
+	cached := storedToken != nil
+	if !cached {
+		storedToken, err = s.findRefreshToken(ctx, claims, req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, ErrInvalidRefreshToken) {
+				s.lockout.recordFailure(ctx, lockoutKeys...)
+			}
+			return nil, err
+		}
 	}
 
-	// CRITICAL: Revoke the old refresh token BEFORE generating new ones
-	// This prevents reuse of stolen tokens
-	err = s.tokenRepository.RevokeRefreshToken(ctx, req.RefreshToken)
+	// Served from the profile cache when possible; status changes invalidate it
+	user, err := s.profileService.GetUser(ctx, storedToken.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to revoke old refresh token: %w", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d not found", storedToken.UserID)
+	}
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	// Roles are looked up again so changes take effect on the next refresh
+	subject := claims.IssuedTo()
+	if err := s.authorize(ctx, &subject); err != nil {
+		return nil, err
 	}
 
 	// Generate NEW token pair (access + refresh) - TOKEN ROTATION
+	// The new pair stays in the same family as the token it replaces
+	// Version 1 refresh tokens are upgraded to the current claims schema here
 	tokenPair, err := s.tokenService.GenerateTokenPair(
-		claims.UserID,
-		claims.AppleID,
-		claims.Email,
+		subject,
+		storedToken.FamilyID,
+		claims.ClientID, // Lifetimes of the client that signed in, not of the presenting one
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate new token pair: %w", err)
 	}
 
-	// Store the NEW refresh token in database
-	err = s.tokenRepository.StoreRefreshToken(ctx, userID, tokenPair.RefreshToken, tokenPair.RefreshTokenExpiresAt)
+	// CRITICAL: Postgres revokes the old refresh token in the same statement that stores the new one,
+	// so a stolen token cannot be rotated twice even if Redis still had it
+	record := newRefreshTokenRecord(storedToken.UserID, storedToken.FamilyID, tokenPair, client)
+	rotated, err := s.tokenRepository.RotateRefreshToken(ctx, req.RefreshToken, tokenPair.RefreshToken, record)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store new refresh token: %w", err)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
 	}
+	if !rotated {
+		err = fmt.Errorf("%w: not found, expired or already used", ErrInvalidRefreshToken)
+		// Redis may still hold a token Postgres has already rotated or revoked:
+		// look it up there so a replayed rotated token revokes its family
+		if cached {
+			if _, findErr := s.findRefreshToken(ctx, claims, req.RefreshToken); findErr != nil {
+				err = findErr
+			}
+		}
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			s.lockout.recordFailure(ctx, lockoutKeys...)
+		}
+		return nil, err
+	}
+	s.cacheRefreshToken(ctx, tokenPair.RefreshToken, record)
+	s.lockout.recordSuccess(ctx, lockoutKeys...)
 
 	// Return BOTH new access and refresh tokens
 	response := &model.RefreshTokenResponse{
@@ -181,3 +652,147 @@ func (s *AuthService) RefreshAccessToken(ctx context.Context, req *model.Refresh
 
 	return response, nil
 }
+
+// authorize sets the subject's roles and the permissions they grant, carried as scopes
+func (s *AuthService) authorize(ctx context.Context, subject *jwt.Subject) error {
+	authorization, err := s.roleRepository.GetAuthorization(ctx, subject.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	subject.Roles = authorization.Roles
+	subject.Scopes = authorization.Permissions
+	return nil
+}
+
+// appleProfile returns the profile from the user object Apple sends on the first sign-in only
+func appleProfile(user *model.AppleUser) model.Profile {
+	if user == nil {
+		return model.Profile{}
+	}
+
+	name := strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+	return model.Profile{DisplayName: name}
+}
+
+// storeRefreshToken persists the refresh token of a newly issued token pair
+// Postgres is the durable store; Redis gets a copy for the refresh fast path
+func (s *AuthService) storeRefreshToken(ctx context.Context, userID int64, familyID string, tokenPair *jwt.TokenPair, client model.ClientInfo) error {
+	record := newRefreshTokenRecord(userID, familyID, tokenPair, client)
+	if err := s.tokenRepository.StoreRefreshToken(ctx, tokenPair.RefreshToken, record); err != nil {
+		return err
+	}
+
+	s.cacheRefreshToken(ctx, tokenPair.RefreshToken, record)
+	return nil
+}
+
+// newRefreshTokenRecord describes the refresh token of a newly issued token pair
+func newRefreshTokenRecord(userID int64, familyID string, tokenPair *jwt.TokenPair, client model.ClientInfo) *model.RefreshToken {
+	return &model.RefreshToken{
+		UserID:    userID,
+		TokenID:   tokenPair.RefreshTokenID,
+		FamilyID:  familyID,
+		ExpiresAt: tokenPair.RefreshTokenExpiresAt,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	}
+}
+
+// cacheRefreshToken writes a refresh token through to Redis, keyed by its jti
+// Failures are only logged: the next refresh of the token falls back to Postgres
+func (s *AuthService) cacheRefreshToken(ctx context.Context, token string, record *model.RefreshToken) {
+	if s.redisTokens == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, refreshTokenCacheTimeout)
+	defer cancel()
+
+	err := s.redisTokens.StoreRefreshToken(ctx, record.UserID, record.TokenID, repository.HashToken(token), record.ExpiresAt)
+	if err != nil {
+		log.Printf("Failed to cache refresh token %s of user %d: %v", record.TokenID, record.UserID, err)
+	}
+}
+
+// consumeCachedRefreshToken claims a refresh token in Redis and describes it from its claims
+// Returns nil if the token is not cached (issued before Redis, evicted, already rotated) or Redis is unavailable
+func (s *AuthService) consumeCachedRefreshToken(ctx context.Context, claims *jwt.TokenClaims, token string) *model.RefreshToken {
+	// Tokens without a jti or family cannot be described from their claims alone
+	if s.redisTokens == nil || claims.ID == "" || claims.FamilyID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, refreshTokenCacheTimeout)
+	defer cancel()
+
+	tokenHash, err := s.redisTokens.ConsumeRefreshToken(ctx, claims.UserID, claims.ID)
+	if err != nil {
+		log.Printf("Failed to read cached refresh token %s of user %d, falling back to database: %v", claims.ID, claims.UserID, err)
+		return nil
+	}
+	if tokenHash == "" || subtle.ConstantTimeCompare([]byte(tokenHash), []byte(repository.HashToken(token))) != 1 {
+		return nil
+	}
+
+	return &model.RefreshToken{
+		UserID:    claims.UserID,
+		TokenID:   claims.ID,
+		FamilyID:  claims.FamilyID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+}
+
+// findRefreshToken looks up a refresh token in Postgres and checks it can be rotated
+// Returns ErrInvalidRefreshToken if it cannot; presenting a rotated token again also revokes its whole family
+func (s *AuthService) findRefreshToken(ctx context.Context, claims *jwt.TokenClaims, token string) (*model.RefreshToken, error) {
+	// Look up refresh token in database (including revoked ones, for reuse detection)
+	storedToken, err := s.tokenRepository.FindRefreshToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token validation failed: %w", err)
+	}
+	if storedToken == nil {
+		return nil, fmt.Errorf("%w: not found", ErrInvalidRefreshToken)
+	}
+
+	// Verify user ID matches
+	if storedToken.UserID != claims.UserID {
+		return nil, fmt.Errorf("%w: user ID mismatch", ErrInvalidRefreshToken)
+	}
+
+	// A rotated token being presented again means someone still holds a copy.
+	// Treat the whole family as compromised.
+	if storedToken.RevokedReason == model.RefreshTokenRotated {
+		s.handleRefreshTokenReuse(ctx, storedToken)
+		return nil, fmt.Errorf("%w: already rotated", ErrInvalidRefreshToken)
+	}
+
+	// Tokens of ended sessions are presented again by clients retrying a refresh after logout
+	if storedToken.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: session ended", ErrInvalidRefreshToken)
+	}
+
+	if time.Now().After(storedToken.ExpiresAt) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidRefreshToken)
+	}
+
+	return storedToken, nil
+}
+
+// handleRefreshTokenReuse revokes the token family of a replayed refresh token
+// Both the legitimate client and the attacker are logged out; the user signs in again
+func (s *AuthService) handleRefreshTokenReuse(ctx context.Context, token *model.RefreshToken) {
+	logger.SecurityEvent("refresh_token_reuse",
+		zap.Int64("user_id", token.UserID),
+		zap.String("token_family", token.FamilyID),
+		zap.String("token_id", token.TokenID),
+	)
+
+	if err := s.sessionService.RevokeTokenFamily(ctx, token.UserID, token.FamilyID); err != nil {
+		logger.SecurityEvent("token_family_revocation_failed",
+			zap.Int64("user_id", token.UserID),
+			zap.String("token_family", token.FamilyID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+)
+
+const testUserID = 7
+
+// fakeRefreshTokenStore keeps refresh tokens in memory, keyed by hash, with the rotation semantics of Postgres
+type fakeRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*model.RefreshToken
+	finds  int
+}
+
+func newFakeRefreshTokenStore() *fakeRefreshTokenStore {
+	return &fakeRefreshTokenStore{tokens: make(map[string]*model.RefreshToken)}
+}
+
+func (s *fakeRefreshTokenStore) StoreRefreshToken(ctx context.Context, token string, record *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *record
+	stored.TokenHash = repository.HashToken(token)
+	s.tokens[stored.TokenHash] = &stored
+	return nil
+}
+
+func (s *fakeRefreshTokenStore) FindRefreshToken(ctx context.Context, token string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finds++
+	stored, ok := s.tokens[repository.HashToken(token)]
+	if !ok {
+		return nil, nil
+	}
+	found := *stored
+	return &found, nil
+}
+
+func (s *fakeRefreshTokenStore) RotateRefreshToken(ctx context.Context, token, newToken string, record *model.RefreshToken) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[repository.HashToken(token)]
+	if !ok || stored.UserID != record.UserID || stored.RevokedAt != nil || !time.Now().Before(stored.ExpiresAt) {
+		return false, nil
+	}
+	s.revoke(stored, model.RefreshTokenRotated)
+
+	replacement := *record
+	replacement.TokenHash = repository.HashToken(newToken)
+	s.tokens[replacement.TokenHash] = &replacement
+	return true, nil
+}
+
+func (s *fakeRefreshTokenStore) RevokeRefreshToken(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.tokens[repository.HashToken(token)]; ok && stored.RevokedAt == nil {
+		s.revoke(stored, model.RefreshTokenLogout)
+	}
+	return nil
+}
+
+func (s *fakeRefreshTokenStore) RevokeAllUserTokens(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.tokens {
+		if stored.UserID == userID && stored.RevokedAt == nil {
+			s.revoke(stored, model.RefreshTokenLogout)
+		}
+	}
+	return nil
+}
+
+func (s *fakeRefreshTokenStore) RevokeTokenFamily(ctx context.Context, userID int64, familyID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokenIDs []string
+	for _, stored := range s.tokens {
+		if stored.UserID == userID && stored.FamilyID == familyID && stored.RevokedAt == nil {
+			s.revoke(stored, model.RefreshTokenLogout)
+			tokenIDs = append(tokenIDs, stored.TokenID)
+		}
+	}
+	return tokenIDs, nil
+}
+
+func (s *fakeRefreshTokenStore) ListActiveSessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	return nil, errors.New("not implemented")
+}
+
+// revoke marks a token revoked; the caller holds s.mu
+func (s *fakeRefreshTokenStore) revoke(stored *model.RefreshToken, reason string) {
+	now := time.Now()
+	stored.RevokedAt = &now
+	stored.RevokedReason = reason
+}
+
+// fakeRedisTokens keeps the Redis copies of refresh tokens in memory, or fails every call with err
+type fakeRedisTokens struct {
+	mu     sync.Mutex
+	hashes map[string]string // "userID:tokenID" -> token hash
+	err    error
+}
+
+func newFakeRedisTokens() *fakeRedisTokens {
+	return &fakeRedisTokens{hashes: make(map[string]string)}
+}
+
+func redisTokenKey(userID int64, tokenID string) string {
+	return fmt.Sprintf("%d:%s", userID, tokenID)
+}
+
+func (r *fakeRedisTokens) StoreRefreshToken(ctx context.Context, userID int64, tokenID, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	r.hashes[redisTokenKey(userID, tokenID)] = tokenHash
+	return nil
+}
+
+func (r *fakeRedisTokens) GetRefreshToken(ctx context.Context, userID int64, tokenID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.hashes[redisTokenKey(userID, tokenID)], nil
+}
+
+func (r *fakeRedisTokens) ConsumeRefreshToken(ctx context.Context, userID int64, tokenID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return "", r.err
+	}
+	key := redisTokenKey(userID, tokenID)
+	tokenHash := r.hashes[key]
+	delete(r.hashes, key)
+	return tokenHash, nil
+}
+
+func (r *fakeRedisTokens) DeleteRefreshToken(ctx context.Context, userID int64, tokenID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	delete(r.hashes, redisTokenKey(userID, tokenID))
+	return nil
+}
+
+func (r *fakeRedisTokens) DeleteAllUserTokens(ctx context.Context, userID int64) error {
+	return errors.New("not implemented")
+}
+
+// cached reports whether Redis holds a copy of a refresh token
+func (r *fakeRedisTokens) cached(claims *jwt.TokenClaims) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.hashes[redisTokenKey(claims.UserID, claims.ID)]
+	return ok
+}
+
+// fakeBlacklist records the token families and users whose access tokens were revoked
+type fakeBlacklist struct {
+	mu       sync.Mutex
+	families []string
+	users    []int64
+}
+
+func (b *fakeBlacklist) AddToBlacklist(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	return nil
+}
+
+func (b *fakeBlacklist) IsBlacklisted(ctx context.Context, tokenID string) (bool, error) {
+	return false, nil
+}
+
+func (b *fakeBlacklist) RevokeUserTokens(ctx context.Context, userID int64, revokedAt time.Time, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.users = append(b.users, userID)
+	return nil
+}
+
+func (b *fakeBlacklist) RevokeTokenFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.families = append(b.families, familyID)
+	return nil
+}
+
+func (b *fakeBlacklist) IsTokenRevoked(ctx context.Context, tokenID, familyID string, userID int64, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+// fakeUserStore serves users from memory; other UserStore methods are not used by these tests
+type fakeUserStore struct {
+	repository.UserStore
+	users map[int64]*model.User
+}
+
+func (s *fakeUserStore) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	return s.users[id], nil
+}
+
+// noRoles grants no roles to anyone
+type noRoles struct{}
+
+func (noRoles) GetAuthorization(ctx context.Context, userID int64) (*model.Authorization, error) {
+	return &model.Authorization{}, nil
+}
+
+// refreshTestEnv is an AuthService over in-memory stores
+type refreshTestEnv struct {
+	auth         *AuthService
+	tokenService *jwt.TokenService
+	tokens       *fakeRefreshTokenStore
+	redis        *fakeRedisTokens // nil without the Redis fast path
+	blacklist    *fakeBlacklist
+}
+
+func newRefreshTestEnv(cached bool) *refreshTestEnv {
+	env := &refreshTestEnv{
+		tokenService: jwt.NewTokenService(jwt.Config{SecretKey: "test-secret-key-of-at-least-32-bytes"}),
+		tokens:       newFakeRefreshTokenStore(),
+		blacklist:    &fakeBlacklist{},
+	}
+
+	users := &fakeUserStore{users: map[int64]*model.User{testUserID: {ID: testUserID}}}
+	sessions := NewSessionService(env.tokens, env.blacklist, env.tokenService)
+	env.auth = NewAuthService(nil, nil, users, nil, env.tokens, noRoles{}, env.tokenService, sessions, nil, nil, NewProfileService(users))
+	if cached {
+		env.redis = newFakeRedisTokens()
+		sessions.WithRedisTokens(env.redis)
+		env.auth.WithRedisTokens(env.redis)
+	}
+	return env
+}
+
+// signIn issues and stores the first token pair of a new family and returns its refresh token
+func (e *refreshTestEnv) signIn(t *testing.T) string {
+	t.Helper()
+
+	familyID := jwt.NewFamilyID()
+	tokenPair, err := e.tokenService.GenerateTokenPair(jwt.Subject{UserID: testUserID}, familyID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.auth.storeRefreshToken(context.Background(), testUserID, familyID, tokenPair, model.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	return tokenPair.RefreshToken
+}
+
+// refresh presents a refresh token and returns the rotated one
+func (e *refreshTestEnv) refresh(refreshToken string) (string, error) {
+	response, err := e.auth.RefreshAccessToken(context.Background(), &model.RefreshTokenRequest{RefreshToken: refreshToken}, model.ClientInfo{IPAddress: "192.0.2.1"})
+	if err != nil {
+		return "", err
+	}
+	return response.RefreshToken, nil
+}
+
+// claims returns the claims of a refresh token issued by the environment
+func (e *refreshTestEnv) claims(t *testing.T, refreshToken string) *jwt.TokenClaims {
+	t.Helper()
+
+	claims, err := e.tokenService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestRefreshAccessToken_Rotates(t *testing.T) {
+	tests := []struct {
+		name      string
+		cached    bool
+		redisErr  error
+		wantFinds int // Postgres lookups over two refreshes
+	}{
+		{name: "uncached", wantFinds: 2},
+		{name: "cached", cached: true, wantFinds: 0},
+		{name: "redis unavailable", cached: true, redisErr: errors.New("connection refused"), wantFinds: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(tt.cached)
+			if tt.redisErr != nil {
+				env.redis.err = tt.redisErr
+			}
+
+			first := env.signIn(t)
+			second, err := env.refresh(first)
+			if err != nil {
+				t.Fatalf("first refresh: %v", err)
+			}
+			third, err := env.refresh(second)
+			if err != nil {
+				t.Fatalf("second refresh: %v", err)
+			}
+
+			if env.tokens.finds != tt.wantFinds {
+				t.Errorf("Postgres lookups = %d, want %d", env.tokens.finds, tt.wantFinds)
+			}
+			for i, token := range []string{first, second} {
+				stored, _ := env.tokens.FindRefreshToken(context.Background(), token)
+				if stored == nil || stored.RevokedReason != model.RefreshTokenRotated {
+					t.Errorf("token %d not revoked as rotated: %+v", i+1, stored)
+				}
+			}
+			if env.redis != nil && tt.redisErr == nil {
+				if env.redis.cached(env.claims(t, second)) {
+					t.Error("rotated token still cached")
+				}
+				if !env.redis.cached(env.claims(t, third)) {
+					t.Error("new token not cached")
+				}
+			}
+			if len(env.blacklist.families) != 0 {
+				t.Errorf("families revoked: %v", env.blacklist.families)
+			}
+		})
+	}
+}
+
+func TestRefreshAccessToken_DetectsReuse(t *testing.T) {
+	tests := []struct {
+		name   string
+		cached bool
+
+		// The Redis copy of the replayed token outlived its rotation in Postgres,
+		// e.g. it was written back by a request that lost the race to rotate it
+		staleCopy bool
+	}{
+		{name: "uncached"},
+		{name: "cached", cached: true},
+		{name: "cached copy outlived rotation", cached: true, staleCopy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(tt.cached)
+			ctx := context.Background()
+
+			first := env.signIn(t)
+			second, err := env.refresh(first)
+			if err != nil {
+				t.Fatalf("refresh: %v", err)
+			}
+			if tt.staleCopy {
+				claims := env.claims(t, first)
+				env.redis.StoreRefreshToken(ctx, claims.UserID, claims.ID, repository.HashToken(first), claims.ExpiresAt.Time)
+			}
+
+			// Replaying the rotated token revokes the whole family
+			if _, err := env.refresh(first); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("replay: err = %v, want ErrInvalidRefreshToken", err)
+			}
+			family := env.claims(t, first).FamilyID
+			if len(env.blacklist.families) != 1 || env.blacklist.families[0] != family {
+				t.Errorf("families revoked = %v, want [%s]", env.blacklist.families, family)
+			}
+
+			// including the token the legitimate client holds, in Postgres and in Redis
+			stored, _ := env.tokens.FindRefreshToken(ctx, second)
+			if stored == nil || stored.RevokedReason != model.RefreshTokenLogout {
+				t.Errorf("latest token not revoked: %+v", stored)
+			}
+			if env.redis != nil && env.redis.cached(env.claims(t, second)) {
+				t.Error("latest token still cached")
+			}
+			if _, err := env.refresh(second); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("refresh with the latest token: err = %v, want ErrInvalidRefreshToken", err)
+			}
+		})
+	}
+}
+
+func TestRefreshAccessToken_EndedSession(t *testing.T) {
+	tests := []struct {
+		name      string
+		cached    bool
+		staleCopy bool // Redis still holds the token, e.g. its removal at logout failed
+	}{
+		{name: "uncached"},
+		{name: "cached", cached: true},
+		{name: "cached copy outlived logout", cached: true, staleCopy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(tt.cached)
+			ctx := context.Background()
+
+			token := env.signIn(t)
+			claims := env.claims(t, token)
+			if err := env.auth.sessionService.RevokeTokenFamily(ctx, claims.UserID, claims.FamilyID); err != nil {
+				t.Fatal(err)
+			}
+			if env.redis != nil && env.redis.cached(claims) {
+				t.Error("revoked token still cached")
+			}
+			if tt.staleCopy {
+				env.redis.StoreRefreshToken(ctx, claims.UserID, claims.ID, repository.HashToken(token), claims.ExpiresAt.Time)
+			}
+
+			if _, err := env.refresh(token); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("err = %v, want ErrInvalidRefreshToken", err)
+			}
+			// A retry after logout is not reuse; the family is not revoked a second time
+			if len(env.blacklist.families) != 1 {
+				t.Errorf("families revoked = %v, want only the logout", env.blacklist.families)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/apple"
+	"github.com/Hamid207/ai-code-test1/pkg/google"
+	"github.com/Hamid207/ai-code-test1/pkg/oidc"
+)
+
+// IdentityProvider verifies ID tokens issued by an external identity provider
+type IdentityProvider interface {
+	// Name is the provider name used in routes and stored with linked identities
+	Name() string
+
+	// VerifyIDToken verifies an ID token and returns the identity it asserts
+	VerifyIDToken(ctx context.Context, idToken, nonce string) (*model.Identity, error)
+}
+
+// OIDCProvider is an IdentityProvider backed by OpenID Connect discovery
+type OIDCProvider struct {
+	name     string
+	verifier *oidc.Verifier
+}
+
+// NewOIDCProvider creates an identity provider for a configured OpenID Connect issuer
+func NewOIDCProvider(name string, verifier *oidc.Verifier) *OIDCProvider {
+	return &OIDCProvider{
+		name:     name,
+		verifier: verifier,
+	}
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// VerifyIDToken verifies an ID token with the provider's published keys
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*model.Identity, error) {
+	claims, err := p.verifier.VerifyIDToken(ctx, idToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s token: %w", p.name, err)
+	}
+
+	return &model.Identity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Profile: model.Profile{
+			DisplayName: claims.Name,
+			AvatarURL:   claims.Picture,
+			Locale:      claims.Locale,
+		},
+	}, nil
+}
+
+// AppleProvider is an IdentityProvider for Sign in with Apple
+// Apple ID tokens must carry the nonce the client requested them with
+type AppleProvider struct {
+	verifier *apple.Verifier
+}
+
+// NewAppleProvider creates an identity provider for Sign in with Apple
+func NewAppleProvider(verifier *apple.Verifier) *AppleProvider {
+	return &AppleProvider{
+		verifier: verifier,
+	}
+}
+
+// Name returns the provider name
+func (p *AppleProvider) Name() string {
+	return model.ProviderApple
+}
+
+// VerifyIDToken verifies an Apple ID token
+func (p *AppleProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*model.Identity, error) {
+	claims, err := p.verifier.VerifyIDToken(idToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify apple token: %w", err)
+	}
+
+	return &model.Identity{
+		Provider:      model.ProviderApple,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified == "true",
+	}, nil
+}
+
+// GoogleProvider is an IdentityProvider for Sign in with Google
+type GoogleProvider struct {
+	verifier *google.Verifier
+}
+
+// NewGoogleProvider creates an identity provider for Sign in with Google
+func NewGoogleProvider(verifier *google.Verifier) *GoogleProvider {
+	return &GoogleProvider{
+		verifier: verifier,
+	}
+}
+
+// Name returns the provider name
+func (p *GoogleProvider) Name() string {
+	return model.ProviderGoogle
+}
+
+// VerifyIDToken verifies a Google ID token; the nonce is not used
+func (p *GoogleProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*model.Identity, error) {
+	claims, err := p.verifier.VerifyIDToken(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify google token: %w", err)
+	}
+
+	return &model.Identity{
+		Provider:      model.ProviderGoogle,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Profile:       googleProfile(claims),
+	}, nil
+}
+
+// googleProfile returns the profile asserted by a Google ID token
+func googleProfile(claims *google.GoogleClaims) model.Profile {
+	name := claims.Name
+	if name == "" {
+		name = strings.TrimSpace(claims.GivenName + " " + claims.FamilyName)
+	}
+
+	return model.Profile{
+		DisplayName: name,
+		AvatarURL:   claims.Picture,
+		Locale:      claims.Locale,
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+)
+
+var (
+	// ErrIdentityNotFound is returned when the user has no identity at the requested provider
+	ErrIdentityNotFound = errors.New("identity not found")
+
+	// ErrIdentityConflict is returned when an identity cannot be linked because it is
+	// already linked to another user, or the user already has an identity at the provider
+	ErrIdentityConflict = errors.New("identity conflict")
+
+	// ErrLastIdentity is returned when unlinking would leave the user without a login method
+	// The repository decides this atomically, so its sentinel is used as is
+	ErrLastIdentity = repository.ErrLastIdentity
+)
+
+// IdentityService manages the provider identities linked to the current user
+type IdentityService struct {
+	identityRepository *repository.IdentityRepository
+	appleTokens        *AppleTokenService
+	providers          map[string]IdentityProvider
+}
+
+// NewIdentityService creates a new identity service
+// appleTokens may be nil when Apple token exchange is not configured
+func NewIdentityService(
+	identityRepo *repository.IdentityRepository,
+	appleTokens *AppleTokenService,
+	providers ...IdentityProvider,
+) *IdentityService {
+	s := &IdentityService{
+		identityRepository: identityRepo,
+		appleTokens:        appleTokens,
+		providers:          make(map[string]IdentityProvider),
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+	}
+	return s
+}
+
+// ListIdentities returns the identities linked to the current user
+func (s *IdentityService) ListIdentities(ctx context.Context, claims *jwt.TokenClaims) (*model.IdentityListResponse, error) {
+	identities, err := s.identityRepository.ListByUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return &model.IdentityListResponse{Identities: identities}, nil
+}
+
+// LinkIdentity links a provider login to the current user
+// The user proves control of the login by presenting a fresh ID token from the provider.
+// Linking an identity the user already holds is a no-op.
+func (s *IdentityService) LinkIdentity(ctx context.Context, claims *jwt.TokenClaims, providerName string, req *model.LinkIdentityRequest) (*model.Identity, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, req.IDToken, req.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+
+	existing, err := s.identityRepository.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if existing != nil && existing.UserID == claims.UserID {
+		return existing, nil
+	}
+
+	linked, err := s.identityRepository.Link(ctx, claims.UserID, identity)
+	if errors.Is(err, repository.ErrIdentityTaken) || errors.Is(err, repository.ErrProviderLinked) {
+		return nil, fmt.Errorf("%w: %v", ErrIdentityConflict, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	// Obtain an Apple refresh token, as at sign-in; linking does not depend on it
+	if linked.Provider == model.ProviderApple && req.AuthorizationCode != "" && s.appleTokens != nil {
+		if err := s.appleTokens.RedeemAuthorizationCode(ctx, claims.UserID, linked.Subject, req.AuthorizationCode); err != nil {
+			log.Printf("Failed to redeem Apple authorization code for user %d: %v", claims.UserID, err)
+		}
+	}
+
+	return linked, nil
+}
+
+// UnlinkIdentity removes the current user's identity at a provider
+// Refuses to remove the user's last login method
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, claims *jwt.TokenClaims, provider string) error {
+	deleted, err := s.identityRepository.DeleteUnlessLast(ctx, claims.UserID, provider)
+	if errors.Is(err, ErrLastIdentity) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	if !deleted {
+		return ErrIdentityNotFound
+	}
+
+	// Disconnecting Apple must revoke the Apple tokens, as on account deletion
+	// The identity is already gone, so failures are only logged
+	if provider == model.ProviderApple && s.appleTokens != nil {
+		if err := s.appleTokens.RevokeTokens(ctx, claims.UserID); err != nil {
+			log.Printf("Failed to revoke Apple tokens for user %d: %v", claims.UserID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// keyRingRefreshInterval is how often the key ring is reloaded from the database,
+// picking up keys rotated by other instances well before they activate
+const keyRingRefreshInterval = time.Minute
+
+// KeyRotationService maintains the token signing key ring stored in the database
+// New keys are published publishDelay before they start signing, and older keys keep
+// verifying until every token they signed has expired (retireAfter)
+type KeyRotationService struct {
+	keyRepository    *repository.SigningKeyRepository
+	ring             *jwt.KeyRing
+	algorithm        string
+	rotationInterval time.Duration // 0 disables scheduled rotation
+	publishDelay     time.Duration
+	retireAfter      time.Duration
+}
+
+// NewKeyRotationService creates a new key rotation service maintaining ring
+func NewKeyRotationService(
+	keyRepo *repository.SigningKeyRepository,
+	ring *jwt.KeyRing,
+	algorithm string,
+	rotationInterval time.Duration,
+	publishDelay time.Duration,
+	retireAfter time.Duration,
+) *KeyRotationService {
+	return &KeyRotationService{
+		keyRepository:    keyRepo,
+		ring:             ring,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		publishDelay:     publishDelay,
+		retireAfter:      retireAfter,
+	}
+}
+
+// Initialize loads the key ring, storing a first key if the database has none
+// initialKey (e.g. the key configured in JWT_SIGNING_KEY_PATH) becomes the first key;
+// if nil a new key is generated
+func (s *KeyRotationService) Initialize(ctx context.Context, initialKey *jwt.SigningKey) error {
+	if initialKey == nil {
+		var err error
+		initialKey, err = jwt.GenerateSigningKey(s.algorithm)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	record, err := newSigningKeyRecord(initialKey, now)
+	if err != nil {
+		return err
+	}
+
+	created, err := s.keyRepository.CreateFirst(ctx, record, now)
+	if err != nil {
+		return fmt.Errorf("failed to store first signing key: %w", err)
+	}
+	if created {
+		log.Printf("Stored first signing key %s (%s)", record.ID, record.Algorithm)
+	}
+
+	return s.Reload(ctx)
+}
+
+// Reload replaces the key ring with the usable keys in the database
+func (s *KeyRotationService) Reload(ctx context.Context) error {
+	now := time.Now()
+	records, err := s.keyRepository.ListUsable(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]jwt.RingKey, 0, len(records))
+	for _, record := range records {
+		privateKey, err := jwt.ParsePrivateKeyPEM(record.PrivateKeyPEM)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key %s: %w", record.ID, err)
+		}
+
+		signingKey, err := jwt.NewSigningKey(privateKey, record.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", record.ID, err)
+		}
+
+		key := jwt.RingKey{Key: signingKey, ActivatesAt: record.ActivatesAt}
+		if record.ExpiresAt != nil {
+			key.ExpiresAt = *record.ExpiresAt
+		}
+		keys = append(keys, key)
+	}
+
+	s.ring.Replace(keys)
+	return nil
+}
+
+// Rotate adds a new signing key, activating after the publish delay, and schedules
+// the current keys for retirement once every token they can still sign has expired
+func (s *KeyRotationService) Rotate(ctx context.Context) (*model.SigningKey, error) {
+	return s.rotate(ctx, time.Time{})
+}
+
+// Run reloads the key ring, rotates keys on schedule and removes retired keys until ctx is done
+func (s *KeyRotationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(keyRingRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.maintain(ctx)
+		}
+	}
+}
+
+// maintain performs one round of key ring maintenance
+func (s *KeyRotationService) maintain(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return
+	}
+
+	if s.rotationDue(time.Now()) {
+		// Another instance may rotate at the same time: skip if a key was activated
+		// within the interval, as seen inside the rotation's lock
+		if _, err := s.rotate(ctx, time.Now().Add(-s.rotationInterval)); err != nil {
+			log.Printf("Failed to rotate signing key: %v", err)
+		}
+	}
+
+	deleted, err := s.keyRepository.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to delete retired signing keys: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d retired signing keys", deleted)
+	}
+}
+
+// rotationDue reports whether the newest key was activated more than the rotation interval ago
+func (s *KeyRotationService) rotationDue(now time.Time) bool {
+	if s.rotationInterval <= 0 {
+		return false
+	}
+
+	keys := s.ring.Keys(now)
+	if len(keys) == 0 {
+		return true
+	}
+	newest := keys[len(keys)-1]
+	return now.Sub(newest.ActivatesAt) >= s.rotationInterval
+}
+
+// rotate generates and stores a new key
+// A non-zero notActivatedAfter skips the rotation if a newer key exists, returning nil
+func (s *KeyRotationService) rotate(ctx context.Context, notActivatedAfter time.Time) (*model.SigningKey, error) {
+	signingKey, err := jwt.GenerateSigningKey(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	activatesAt := time.Now().Add(s.publishDelay)
+	record, err := newSigningKeyRecord(signingKey, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older keys sign until the new key activates; their last tokens expire retireAfter later
+	retireAt := activatesAt.Add(s.retireAfter)
+	rotated, err := s.keyRepository.Rotate(ctx, record, retireAt, notActivatedAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+	if !rotated {
+		return nil, nil
+	}
+
+	logger.SecurityEvent("signing_key_rotated",
+		zap.String("kid", record.ID),
+		zap.String("alg", record.Algorithm),
+		zap.Time("activates_at", activatesAt),
+		zap.Time("previous_keys_retire_at", retireAt),
+	)
+
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// newSigningKeyRecord builds the database record of a signing key
+func newSigningKeyRecord(key *jwt.SigningKey, activatesAt time.Time) (*model.SigningKey, error) {
+	privateKeyPEM, err := key.PrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SigningKey{
+		ID:            key.ID,
+		Algorithm:     key.Method.Alg(),
+		PrivateKeyPEM: privateKeyPEM,
+		ActivatesAt:   activatesAt,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+)
+
+// applePrivateRelayDomain hosts Apple's per-app relay addresses
+// Relay addresses are never trusted for linking: they cannot prove ownership of a mailbox elsewhere
+const applePrivateRelayDomain = "privaterelay.appleid.com"
+
+// LinkingPolicy decides when a new login may be linked to an existing user with the same email
+// without the user confirming it by signing in with a login the account already has
+type LinkingPolicy struct {
+	trustedDomains map[string]bool
+	trustAny       bool
+}
+
+// NewLinkingPolicy creates a linking policy trusting the given email domains
+// "*" trusts every domain; an empty list disables automatic linking
+func NewLinkingPolicy(trustedDomains []string) *LinkingPolicy {
+	policy := &LinkingPolicy{
+		trustedDomains: make(map[string]bool),
+	}
+	for _, domain := range trustedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "*" {
+			policy.trustAny = true
+			continue
+		}
+		policy.trustedDomains[domain] = true
+	}
+	return policy
+}
+
+// CanAutoLink reports whether identity may be linked to the user holding existing
+// Both the new provider and one of the user's current providers must assert the same verified email,
+// and the email's domain must be trusted
+func (p *LinkingPolicy) CanAutoLink(identity *model.Identity, existing []model.Identity) bool {
+	if !identity.EmailVerified || !p.isTrustedDomain(identity.Email) {
+		return false
+	}
+
+	for _, linked := range existing {
+		if linked.EmailVerified && strings.EqualFold(linked.Email, identity.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrustedDomain reports whether the domain of email is trusted for automatic linking
+func (p *LinkingPolicy) isTrustedDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	if domain == applePrivateRelayDomain {
+		return false
+	}
+
+	return p.trustAny || p.trustedDomains[domain]
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrLockedOut is returned while too many failed attempts block authentication
+var ErrLockedOut = errors.New("too many failed attempts")
+
+// ErrCaptchaRequired is returned when a sign-in needs a solved CAPTCHA after repeated failures
+var ErrCaptchaRequired = errors.New("captcha required")
+
+// LockedOutError carries how long authentication stays blocked
+// errors.Is(err, ErrLockedOut) reports true for it
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrLockedOut, e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrLockedOut) match
+func (e *LockedOutError) Is(target error) bool {
+	return target == ErrLockedOut
+}
+
+// lockoutTimeout bounds the Redis calls of one check or record
+// Lockouts fail open, so an unresponsive Redis must not hold up authentication
+const lockoutTimeout = 200 * time.Millisecond
+
+// CaptchaVerifier verifies CAPTCHA tokens solved by clients (see captcha.Verifier)
+type CaptchaVerifier interface {
+	// Verify reports whether a token was solved; remoteIP is the client's address, if known
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// LockoutPolicy configures brute-force protection; a threshold of 0 disables it
+type LockoutPolicy struct {
+	IPThreshold      int64         // Failed attempts per IP address before it is locked out
+	SubjectThreshold int64         // Failed attempts per provider subject or refresh token family
+	Window           time.Duration // Failures are forgotten this long after the last one
+	Duration         time.Duration // First lockout; doubles with every further failure
+	MaxDuration      time.Duration
+	CaptchaThreshold int64 // Failed sign-ins after which a CAPTCHA is required, if a verifier is set
+}
+
+// lockoutScope names what failed attempts are counted against
+type lockoutScope string
+
+const (
+	lockoutScopeIP      lockoutScope = "ip"      // Client IP address
+	lockoutScopeSubject lockoutScope = "subject" // Subject of a provider ID token whose signature verified
+	lockoutScopeFamily  lockoutScope = "family"  // Refresh token family (one per sign-in)
+)
+
+// lockoutKey identifies a counter of failed attempts
+type lockoutKey struct {
+	scope lockoutScope
+	id    string
+}
+
+func (k lockoutKey) String() string {
+	return string(k.scope) + ":" + k.id
+}
+
+// ipLockoutKey counts the failures of a client IP address
+func ipLockoutKey(ipAddress string) lockoutKey {
+	return lockoutKey{scope: lockoutScopeIP, id: ipAddress}
+}
+
+// subjectLockoutKey counts the failures of a provider subject
+func subjectLockoutKey(provider, subject string) lockoutKey {
+	return lockoutKey{scope: lockoutScopeSubject, id: provider + ":" + subject}
+}
+
+// familyLockoutKey counts the failures of a refresh token family
+func familyLockoutKey(familyID string) lockoutKey {
+	return lockoutKey{scope: lockoutScopeFamily, id: familyID}
+}
+
+// LockoutService counts failed sign-ins and refreshes and temporarily locks out
+// IP addresses, provider subjects and refresh token families with too many of them.
+// Lockouts double with every failure past the threshold, up to the maximum duration.
+// Counters live in Redis; if it is unavailable attempts are allowed (rate limits still apply).
+// A nil LockoutService allows every attempt.
+type LockoutService struct {
+	lockoutRepository repository.RedisLockoutRepository
+	policy            LockoutPolicy
+	captcha           CaptchaVerifier
+}
+
+// NewLockoutService creates a new lockout service
+func NewLockoutService(lockoutRepo repository.RedisLockoutRepository, policy LockoutPolicy) *LockoutService {
+	return &LockoutService{
+		lockoutRepository: lockoutRepo,
+		policy:            policy,
+	}
+}
+
+// WithCaptcha requires a solved CAPTCHA at sign-in once the IP address has failed CaptchaThreshold times
+func (s *LockoutService) WithCaptcha(verifier CaptchaVerifier) *LockoutService {
+	s.captcha = verifier
+	return s
+}
+
+// check returns a *LockedOutError if any key is locked out
+// With captcha set, it also returns ErrCaptchaRequired if a key reached the CAPTCHA threshold
+// and the client did not send a solved CAPTCHA
+func (s *LockoutService) check(ctx context.Context, client model.ClientInfo, captcha bool, keys ...lockoutKey) error {
+	if s == nil {
+		return nil
+	}
+
+	redisCtx, cancel := context.WithTimeout(ctx, lockoutTimeout)
+	defer cancel()
+
+	captchaRequired := false
+	for _, key := range keys {
+		failures, lockedFor, err := s.lockoutRepository.GetFailures(redisCtx, key.String())
+		if err != nil {
+			// Fail open: brute-force protection must not take sign-in down
+			log.Printf("Failed to check lockout of %s: %v", key.scope, err)
+			continue
+		}
+		if lockedFor > 0 {
+			return &LockedOutError{RetryAfter: lockedFor}
+		}
+		if captcha && s.policy.CaptchaThreshold > 0 && failures >= s.policy.CaptchaThreshold {
+			captchaRequired = true
+		}
+	}
+
+	if captchaRequired && s.captcha != nil {
+		return s.verifyCaptcha(ctx, client)
+	}
+	return nil
+}
+
+// verifyCaptcha checks the CAPTCHA token sent by the client
+func (s *LockoutService) verifyCaptcha(ctx context.Context, client model.ClientInfo) error {
+	if client.CaptchaToken == "" {
+		return ErrCaptchaRequired
+	}
+
+	solved, err := s.captcha.Verify(ctx, client.CaptchaToken, client.IPAddress)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	if !solved {
+		return ErrCaptchaRequired
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt against every key and locks out keys past their threshold
+func (s *LockoutService) recordFailure(ctx context.Context, keys ...lockoutKey) {
+	if s == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lockoutTimeout)
+	defer cancel()
+
+	for _, key := range keys {
+		failures, err := s.lockoutRepository.RecordFailure(ctx, key.String(), s.policy.Window)
+		if err != nil {
+			log.Printf("Failed to record authentication failure of %s: %v", key.scope, err)
+			continue
+		}
+
+		threshold := s.threshold(key.scope)
+		if threshold == 0 || failures < threshold {
+			continue
+		}
+
+		duration := s.lockoutDuration(failures - threshold)
+		if err := s.lockoutRepository.Lock(ctx, key.String(), duration, s.policy.Window); err != nil {
+			log.Printf("Failed to lock out %s: %v", key.scope, err)
+			continue
+		}
+
+		logger.SecurityEvent("authentication_lockout",
+			zap.String("scope", string(key.scope)),
+			zap.String("key", key.id),
+			zap.Int64("failures", failures),
+			zap.Duration("duration", duration),
+		)
+	}
+}
+
+// recordSuccess clears the failures of keys after a successful attempt
+// IP addresses keep theirs: a credential-stuffing client succeeds now and then
+func (s *LockoutService) recordSuccess(ctx context.Context, keys ...lockoutKey) {
+	if s == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lockoutTimeout)
+	defer cancel()
+
+	for _, key := range keys {
+		if key.scope == lockoutScopeIP {
+			continue
+		}
+		if err := s.lockoutRepository.ResetFailures(ctx, key.String()); err != nil {
+			log.Printf("Failed to reset authentication failures of %s: %v", key.scope, err)
+		}
+	}
+}
+
+// threshold returns the failures after which a key is locked out (0: never)
+func (s *LockoutService) threshold(scope lockoutScope) int64 {
+	if scope == lockoutScopeIP {
+		return s.policy.IPThreshold
+	}
+	return s.policy.SubjectThreshold
+}
+
+// lockoutDuration doubles the first lockout for every failure past the threshold, up to the maximum
+func (s *LockoutService) lockoutDuration(excess int64) time.Duration {
+	duration := s.policy.Duration
+	for i := int64(0); i < excess && duration < s.policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, s.policy.MaxDuration)
+}
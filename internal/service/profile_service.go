@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/Hamid207/ai-code-test1/pkg/validator"
+)
+
+// ErrInvalidProfile is returned when a profile edit contains an invalid field
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// ProfileService manages the profile of the current user
+// Reads are served from the user store's cache, which every write invalidates
+type ProfileService struct {
+	userRepository repository.UserStore
+}
+
+// NewProfileService creates a new profile service
+func NewProfileService(userRepo repository.UserStore) *ProfileService {
+	return &ProfileService{
+		userRepository: userRepo,
+	}
+}
+
+// GetProfile returns the current user
+func (s *ProfileService) GetProfile(ctx context.Context, claims *jwt.TokenClaims) (*model.User, error) {
+	user, err := s.GetUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// GetUser returns a user, or nil if they do not exist
+func (s *ProfileService) GetUser(ctx context.Context, userID int64) (*model.User, error) {
+	user, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateProfile validates and applies an edit of the current user's profile
+func (s *ProfileService) UpdateProfile(ctx context.Context, claims *jwt.TokenClaims, req *model.UpdateProfileRequest) (*model.User, error) {
+	if err := validateProfileUpdate(req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepository.UpdateProfile(ctx, claims.UserID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// ApplyProviderProfile fills the user's empty profile fields from an identity provider at sign-in
+// Invalid provider values are dropped rather than failing sign-in. Returns the user, updated if changed.
+func (s *ProfileService) ApplyProviderProfile(ctx context.Context, user *model.User, profile model.Profile) *model.User {
+	profile = sanitizeProviderProfile(profile)
+	if profile.IsEmpty() {
+		return user
+	}
+
+	updated, err := s.userRepository.FillProfile(ctx, user.ID, profile)
+	if err != nil {
+		// The profile can be completed on a later sign-in or edited by the user
+		log.Printf("Failed to store provider profile for user %d: %v", user.ID, err)
+		return user
+	}
+	if updated == nil {
+		return user
+	}
+
+	return updated
+}
+
+// validateProfileUpdate validates the fields set in a profile edit; empty strings clear a field
+func validateProfileUpdate(req *model.UpdateProfileRequest) error {
+	if req.DisplayName != nil {
+		*req.DisplayName = strings.TrimSpace(*req.DisplayName)
+		if *req.DisplayName != "" {
+			if err := validator.ValidateDisplayName(*req.DisplayName); err != nil {
+				return fmt.Errorf("%w: display_name: %v", ErrInvalidProfile, err)
+			}
+		}
+	}
+
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		if err := validator.ValidateAvatarURL(*req.AvatarURL); err != nil {
+			return fmt.Errorf("%w: avatar_url: %v", ErrInvalidProfile, err)
+		}
+	}
+
+	if req.Locale != nil && *req.Locale != "" {
+		if err := validator.ValidateLocale(*req.Locale); err != nil {
+			return fmt.Errorf("%w: locale: %v", ErrInvalidProfile, err)
+		}
+	}
+
+	return nil
+}
+
+// sanitizeProviderProfile drops provider profile fields that would not pass validation
+func sanitizeProviderProfile(profile model.Profile) model.Profile {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	if profile.DisplayName != "" && validator.ValidateDisplayName(profile.DisplayName) != nil {
+		profile.DisplayName = ""
+	}
+	if profile.AvatarURL != "" && validator.ValidateAvatarURL(profile.AvatarURL) != nil {
+		profile.AvatarURL = ""
+	}
+	if profile.Locale != "" && validator.ValidateLocale(profile.Locale) != nil {
+		profile.Locale = ""
+	}
+	return profile
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"github.com/Hamid207/ai-code-test1/pkg/validator"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidRole is returned when a role name is invalid
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrRoleNotFound is returned when a role does not exist
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrRoleExists is returned when creating a role whose name is taken
+	ErrRoleExists = errors.New("role already exists")
+
+	// ErrSystemRole is returned when changing or deleting a role managed by migrations
+	ErrSystemRole = errors.New("system roles cannot be changed")
+
+	// ErrUnknownPermission is returned when granting a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+
+	// ErrRoleNotAssigned is returned when unassigning a role the user does not hold
+	ErrRoleNotAssigned = errors.New("role not assigned")
+
+	// ErrAdminRequired is returned when a non-admin assigns or unassigns the admin role
+	ErrAdminRequired = errors.New("only admins can assign or unassign the admin role")
+
+	// ErrPermissionNotHeld is returned when granting a permission the caller's token does not carry
+	ErrPermissionNotHeld = errors.New("cannot grant a permission the caller does not hold")
+
+	// ErrLastAdmin is returned when unassigning the admin role from its only holder
+	// The repository decides this atomically, so its sentinel is used as is
+	ErrLastAdmin = repository.ErrLastAdmin
+)
+
+// RoleService manages roles, the permissions they grant and their assignment to users
+// Access tokens carry the user's roles and permissions. Changes that may take permissions
+// away revoke the affected users' access tokens, so clients refresh and get current ones.
+type RoleService struct {
+	roleRepository *repository.RoleRepository
+	userRepository repository.UserStore
+	sessionService *SessionService
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(roleRepo *repository.RoleRepository, userRepo repository.UserStore, sessionService *SessionService) *RoleService {
+	return &RoleService{
+		roleRepository: roleRepo,
+		userRepository: userRepo,
+		sessionService: sessionService,
+	}
+}
+
+// ListPermissions returns every permission roles can grant
+func (s *RoleService) ListPermissions(ctx context.Context) (*model.PermissionListResponse, error) {
+	permissions, err := s.roleRepository.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PermissionListResponse{Permissions: permissions}, nil
+}
+
+// ListRoles returns every role with its permissions
+func (s *RoleService) ListRoles(ctx context.Context) (*model.RoleListResponse, error) {
+	roles, err := s.roleRepository.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RoleListResponse{Roles: roles}, nil
+}
+
+// CreateRole creates a role granting only permissions the caller holds
+func (s *RoleService) CreateRole(ctx context.Context, claims *jwt.TokenClaims, req *model.CreateRoleRequest) (*model.Role, error) {
+	if err := validator.ValidateRoleName(req.Name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRole, err)
+	}
+	if err := requireHeldPermissions(claims, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := s.roleRepository.CreateRole(ctx, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	logger.SecurityEvent("role_created",
+		zap.String("role", role.Name),
+		zap.Strings("permissions", role.Permissions),
+		zap.Int64("actor_id", claims.UserID),
+	)
+	return role, nil
+}
+
+// SetRolePermissions replaces the permissions a role grants; the caller must hold every one of them
+func (s *RoleService) SetRolePermissions(ctx context.Context, claims *jwt.TokenClaims, name string, req *model.SetRolePermissionsRequest) (*model.Role, error) {
+	if err := requireHeldPermissions(claims, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role, userIDs, err := s.roleRepository.SetRolePermissions(ctx, name, req.Permissions)
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	logger.SecurityEvent("role_permissions_changed",
+		zap.String("role", role.Name),
+		zap.Strings("permissions", role.Permissions),
+		zap.Int64("actor_id", claims.UserID),
+	)
+
+	if err := s.revokeAccessTokens(ctx, userIDs...); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteRole deletes a role, unassigning it from every user
+func (s *RoleService) DeleteRole(ctx context.Context, claims *jwt.TokenClaims, name string) error {
+	userIDs, err := s.roleRepository.DeleteRole(ctx, name)
+	if err != nil {
+		return roleError(err)
+	}
+
+	logger.SecurityEvent("role_deleted",
+		zap.String("role", name),
+		zap.Int("holders", len(userIDs)),
+		zap.Int64("actor_id", claims.UserID),
+	)
+
+	return s.revokeAccessTokens(ctx, userIDs...)
+}
+
+// ListUserRoles returns the roles assigned to a user
+func (s *RoleService) ListUserRoles(ctx context.Context, userID int64) (*model.RoleListResponse, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepository.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RoleListResponse{Roles: roles}, nil
+}
+
+// AssignRole assigns a role to a user and returns the user's roles
+// The user's next access token carries the role; assigning a held role is a no-op.
+// Only admins can assign the admin role, and others only roles whose permissions they hold.
+func (s *RoleService) AssignRole(ctx context.Context, claims *jwt.TokenClaims, userID int64, name string) (*model.RoleListResponse, error) {
+	role, err := s.roleRepository.GetRole(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	if err := authorizeAssignment(claims, role); err != nil {
+		return nil, err
+	}
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.roleRepository.AssignRole(ctx, userID, name, claims.UserID)
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	if assigned {
+		logger.SecurityEvent("role_assigned",
+			zap.Int64("user_id", userID),
+			zap.String("role", name),
+			zap.Int64("actor_id", claims.UserID),
+		)
+	}
+
+	return s.ListUserRoles(ctx, userID)
+}
+
+// UnassignRole removes a role from a user and revokes the user's access tokens
+// Only admins can unassign the admin role, and never from its last holder
+func (s *RoleService) UnassignRole(ctx context.Context, claims *jwt.TokenClaims, userID int64, name string) error {
+	if err := requireAdminFor(claims, name); err != nil {
+		return err
+	}
+
+	unassigned, err := s.roleRepository.UnassignRole(ctx, userID, name)
+	if err != nil {
+		return roleError(err)
+	}
+	if !unassigned {
+		return ErrRoleNotAssigned
+	}
+
+	logger.SecurityEvent("role_unassigned",
+		zap.Int64("user_id", userID),
+		zap.String("role", name),
+		zap.Int64("actor_id", claims.UserID),
+	)
+
+	return s.revokeAccessTokens(ctx, userID)
+}
+
+// requireAdminFor returns ErrAdminRequired if role is the admin role and the caller is not an admin
+// roles:write alone must not let its holders make themselves admins
+func requireAdminFor(claims *jwt.TokenClaims, role string) error {
+	if role == model.RoleAdmin && !claims.HasRole(model.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	return nil
+}
+
+// requireHeldPermissions returns ErrPermissionNotHeld unless the caller's token carries every permission
+// Otherwise a roles:write holder could create a role granting anything and assign it to themselves
+func requireHeldPermissions(claims *jwt.TokenClaims, permissions []string) error {
+	for _, permission := range permissions {
+		if !claims.HasScope(permission) {
+			return fmt.Errorf("%w: %s", ErrPermissionNotHeld, permission)
+		}
+	}
+	return nil
+}
+
+// authorizeAssignment checks that the caller may assign a role (see requireAdminFor and requireHeldPermissions)
+func authorizeAssignment(claims *jwt.TokenClaims, role *model.Role) error {
+	if err := requireAdminFor(claims, role.Name); err != nil {
+		return err
+	}
+	return requireHeldPermissions(claims, role.Permissions)
+}
+
+// requireUser returns ErrUserNotFound if a user does not exist
+func (s *RoleService) requireUser(ctx context.Context, userID int64) error {
+	user, err := s.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// revokeAccessTokens revokes the access tokens of users who may have lost permissions
+// Access tokens outlive role changes otherwise, so a failure is returned rather than logged
+func (s *RoleService) revokeAccessTokens(ctx context.Context, userIDs ...int64) error {
+	var errs []error
+	for _, userID := range userIDs {
+		if err := s.sessionService.RevokeAccessTokens(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// roleError maps role repository errors to service errors
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrRoleNotFound):
+		return ErrRoleNotFound
+	case errors.Is(err, repository.ErrRoleExists):
+		return ErrRoleExists
+	case errors.Is(err, repository.ErrSystemRole):
+		return ErrSystemRole
+	case errors.Is(err, repository.ErrUnknownPermission):
+		return ErrUnknownPermission
+	}
+	return err
+}
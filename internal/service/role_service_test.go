@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+)
+
+// Callers used by the escalation tests
+var (
+	adminClaims = &jwt.TokenClaims{
+		UserID: 1,
+		Roles:  []string{model.RoleAdmin},
+		Scope:  "users:read users:write roles:read roles:write keys:rotate",
+	}
+	roleManagerClaims = &jwt.TokenClaims{
+		UserID: 2,
+		Roles:  []string{"role-manager"},
+		Scope:  "roles:read roles:write users:read",
+	}
+)
+
+func TestRequireAdminFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  *jwt.TokenClaims
+		role    string
+		wantErr error
+	}{
+		{name: "admin assigns admin", claims: adminClaims, role: model.RoleAdmin},
+		{name: "admin assigns other role", claims: adminClaims, role: "support"},
+		{name: "roles:write holder assigns admin", claims: roleManagerClaims, role: model.RoleAdmin, wantErr: ErrAdminRequired},
+		{name: "roles:write holder assigns other role", claims: roleManagerClaims, role: "support"},
+		{
+			// The admin role is checked by name, not by the permissions the caller holds
+			name:    "every permission without the admin role",
+			claims:  &jwt.TokenClaims{UserID: 3, Scope: adminClaims.Scope},
+			role:    model.RoleAdmin,
+			wantErr: ErrAdminRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := requireAdminFor(tt.claims, tt.role); !errors.Is(err, tt.wantErr) {
+				t.Errorf("requireAdminFor() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAssignment(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  *jwt.TokenClaims
+		role    *model.Role
+		wantErr error
+	}{
+		{
+			name:   "admin assigns admin",
+			claims: adminClaims,
+			role:   &model.Role{Name: model.RoleAdmin, Permissions: []string{model.PermissionUsersWrite, model.PermissionKeysRotate}},
+		},
+		{
+			name:    "roles:write holder assigns admin",
+			claims:  roleManagerClaims,
+			role:    &model.Role{Name: model.RoleAdmin, Permissions: []string{model.PermissionRolesRead}},
+			wantErr: ErrAdminRequired,
+		},
+		{
+			name:   "role with held permissions",
+			claims: roleManagerClaims,
+			role:   &model.Role{Name: "support", Permissions: []string{model.PermissionUsersRead}},
+		},
+		{
+			name:   "role without permissions",
+			claims: roleManagerClaims,
+			role:   &model.Role{Name: "beta"},
+		},
+		{
+			name:    "role with a permission the caller lacks",
+			claims:  roleManagerClaims,
+			role:    &model.Role{Name: "operator", Permissions: []string{model.PermissionUsersRead, model.PermissionKeysRotate}},
+			wantErr: ErrPermissionNotHeld,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizeAssignment(tt.claims, tt.role); !errors.Is(err, tt.wantErr) {
+				t.Errorf("authorizeAssignment() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoleService_RejectsEscalation(t *testing.T) {
+	// Every case is rejected before the role repository is used, so none is configured
+	s := &RoleService{}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name: "create role granting a permission the caller lacks",
+			call: func() error {
+				_, err := s.CreateRole(ctx, roleManagerClaims, &model.CreateRoleRequest{
+					Name:        "operator",
+					Permissions: []string{model.PermissionKeysRotate},
+				})
+				return err
+			},
+			wantErr: ErrPermissionNotHeld,
+		},
+		{
+			name: "create role with an invalid name",
+			call: func() error {
+				_, err := s.CreateRole(ctx, adminClaims, &model.CreateRoleRequest{Name: "Operators!"})
+				return err
+			},
+			wantErr: ErrInvalidRole,
+		},
+		{
+			name: "grant a permission the caller lacks to an existing role",
+			call: func() error {
+				_, err := s.SetRolePermissions(ctx, roleManagerClaims, "support", &model.SetRolePermissionsRequest{
+					Permissions: []string{model.PermissionUsersRead, model.PermissionUsersWrite},
+				})
+				return err
+			},
+			wantErr: ErrPermissionNotHeld,
+		},
+		{
+			name: "unassign admin without being an admin",
+			call: func() error {
+				return s.UnassignRole(ctx, roleManagerClaims, 1, model.RoleAdmin)
+			},
+			wantErr: ErrAdminRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
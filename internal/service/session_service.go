@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+)
+
+// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// RefreshTokenStore persists refresh tokens; implemented by repository.TokenRepository
+// It is the source of truth for rotation: Redis only holds copies for the refresh fast path
+type RefreshTokenStore interface {
+	StoreRefreshToken(ctx context.Context, token string, record *model.RefreshToken) error
+
+	// FindRefreshToken returns a refresh token, including revoked and expired ones, or nil if unknown
+	FindRefreshToken(ctx context.Context, token string) (*model.RefreshToken, error)
+
+	// RotateRefreshToken revokes an active refresh token and stores its replacement in one step
+	// Returns false if the token was already revoked or has expired
+	RotateRefreshToken(ctx context.Context, token, newToken string, record *model.RefreshToken) (bool, error)
+
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeAllUserTokens(ctx context.Context, userID int64) error
+
+	// RevokeTokenFamily revokes the active tokens of a family and returns their IDs (jti)
+	RevokeTokenFamily(ctx context.Context, userID int64, familyID string) ([]string, error)
+
+	ListActiveSessions(ctx context.Context, userID int64) ([]model.Session, error)
+}
+
+// SessionService handles session listing and termination (logout) business logic
+type SessionService struct {
+	tokenRepository     RefreshTokenStore
+	blacklistRepository repository.RedisBlacklistRepository
+	tokenService        *jwt.TokenService
+
+	// Optional: refresh token copies kept for the refresh fast path
+	redisTokens repository.RedisTokenRepository
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(
+	tokenRepo RefreshTokenStore,
+	blacklistRepo repository.RedisBlacklistRepository,
+	tokenService *jwt.TokenService,
+) *SessionService {
+	return &SessionService{
+		tokenRepository:     tokenRepo,
+		blacklistRepository: blacklistRepo,
+		tokenService:        tokenService,
+	}
+}
+
+// WithRedisTokens removes the Redis copies of refresh tokens whenever their sessions end
+func (s *SessionService) WithRedisTokens(redisTokens repository.RedisTokenRepository) *SessionService {
+	s.redisTokens = redisTokens
+	return s
+}
+
+// Logout ends the current session
+// Revokes the presented refresh token and blacklists the access token until it expires
+// accessClaims must come from an authenticated request (see middleware.RequireAuth)
+func (s *SessionService) Logout(ctx context.Context, accessClaims *jwt.TokenClaims, req *model.LogoutRequest) error {
+	// Validate refresh token (JWT validation)
+	refreshClaims, err := s.tokenService.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("invalid refresh token: %w", err)
+	}
+
+	// A user may only end their own sessions
+	if refreshClaims.UserID != accessClaims.UserID {
+		return fmt.Errorf("user ID mismatch")
+	}
+
+	// Ending a session revokes the whole token family, which also
+	// invalidates every access token minted within it
+	if refreshClaims.FamilyID != "" {
+		err = s.RevokeTokenFamily(ctx, refreshClaims.UserID, refreshClaims.FamilyID)
+	} else {
+		err = s.tokenRepository.RevokeRefreshToken(ctx, req.RefreshToken)
+		if err == nil && refreshClaims.ID != "" {
+			s.uncacheRefreshTokens(ctx, refreshClaims.UserID, []string{refreshClaims.ID})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	err = s.blacklistRepository.AddToBlacklist(ctx, accessClaims.ID, accessClaims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("failed to blacklist access token: %w", err)
+	}
+
+	return nil
+}
+
+// LogoutAll ends every session of the current user
+// Revokes all refresh tokens and invalidates every access token issued so far
+func (s *SessionService) LogoutAll(ctx context.Context, claims *jwt.TokenClaims) error {
+	// The per-user marker also covers the presented access token
+	return s.RevokeAllUserSessions(ctx, claims.UserID)
+}
+
+// RevokeAllUserSessions revokes every refresh token of a user and every access token issued so far
+func (s *SessionService) RevokeAllUserSessions(ctx context.Context, userID int64) error {
+	err := s.tokenRepository.RevokeAllUserTokens(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	s.uncacheAllRefreshTokens(ctx, userID)
+
+	err = s.blacklistRepository.RevokeUserTokens(ctx, userID, time.Now(), s.tokenService.AccessTokenTTL())
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAccessTokens revokes every access token issued to a user so far, keeping their sessions
+// Clients refresh to obtain new access tokens, which reflect the user's current roles
+func (s *SessionService) RevokeAccessTokens(ctx context.Context, userID int64) error {
+	err := s.blacklistRepository.RevokeUserTokens(ctx, userID, time.Now(), s.tokenService.AccessTokenTTL())
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ListSessions returns the active sessions of the current user
+// The session the request was made from is flagged as current
+func (s *SessionService) ListSessions(ctx context.Context, claims *jwt.TokenClaims) (*model.SessionListResponse, error) {
+	sessions, err := s.tokenRepository.ListActiveSessions(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = claims.FamilyID != "" && sessions[i].ID == claims.FamilyID
+	}
+
+	return &model.SessionListResponse{Sessions: sessions}, nil
+}
+
+// RevokeSession signs the current user out of a single session (device)
+func (s *SessionService) RevokeSession(ctx context.Context, claims *jwt.TokenClaims, sessionID string) error {
+	revoked, err := s.tokenRepository.RevokeTokenFamily(ctx, claims.UserID, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if len(revoked) == 0 {
+		return ErrSessionNotFound
+	}
+	s.uncacheRefreshTokens(ctx, claims.UserID, revoked)
+
+	err = s.blacklistRepository.RevokeTokenFamily(ctx, sessionID, s.tokenService.AccessTokenTTL())
+	if err != nil {
+		return fmt.Errorf("failed to revoke session access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token in a family and blacklists its access tokens
+func (s *SessionService) RevokeTokenFamily(ctx context.Context, userID int64, familyID string) error {
+	revoked, err := s.tokenRepository.RevokeTokenFamily(ctx, userID, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	s.uncacheRefreshTokens(ctx, userID, revoked)
+
+	err = s.blacklistRepository.RevokeTokenFamily(ctx, familyID, s.tokenService.AccessTokenTTL())
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// uncacheRefreshTokens removes the Redis copies of revoked refresh tokens
+// Failures are only logged: Postgres refuses to rotate revoked tokens even if Redis still has them
+func (s *SessionService) uncacheRefreshTokens(ctx context.Context, userID int64, tokenIDs []string) {
+	if s.redisTokens == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, refreshTokenCacheTimeout)
+	defer cancel()
+
+	for _, tokenID := range tokenIDs {
+		if tokenID == "" {
+			continue
+		}
+		if err := s.redisTokens.DeleteRefreshToken(ctx, userID, tokenID); err != nil {
+			log.Printf("Failed to remove cached refresh token %s of user %d: %v", tokenID, userID, err)
+		}
+	}
+}
+
+// uncacheAllRefreshTokens removes the Redis copies of every refresh token of a user
+func (s *SessionService) uncacheAllRefreshTokens(ctx context.Context, userID int64) {
+	if s.redisTokens == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, refreshTokenCacheTimeout)
+	defer cancel()
+
+	if err := s.redisTokens.DeleteAllUserTokens(ctx, userID); err != nil {
+		log.Printf("Failed to remove cached refresh tokens of user %d: %v", userID, err)
+	}
+}
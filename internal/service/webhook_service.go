@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/apple"
+	"github.com/Hamid207/ai-code-test1/pkg/google"
+	"github.com/Hamid207/ai-code-test1/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidNotification is returned when a webhook payload fails verification
+var ErrInvalidNotification = errors.New("invalid notification")
+
+// Sources of webhook events, used to keep their event IDs apart
+const (
+	webhookSourceApple      = "apple"
+	webhookSourceGoogleRISC = "google_risc"
+)
+
+// webhookEventTimeout bounds the Redis calls of replay protection
+const webhookEventTimeout = 200 * time.Millisecond
+
+// WebhookService handles notifications pushed by identity providers
+type WebhookService struct {
+	appleVerifier      *apple.Verifier
+	riscVerifier       *google.RISCVerifier
+	userRepository     repository.UserStore
+	identityRepository *repository.IdentityRepository
+	sessionService     *SessionService
+	accountService     *AccountService
+	appleTokens        *AppleTokenService
+	eventRepository    repository.RedisWebhookEventRepository
+}
+
+// NewWebhookService creates a new webhook service
+// appleTokens may be nil when Apple token exchange is not configured
+func NewWebhookService(
+	appleVerifier *apple.Verifier,
+	riscVerifier *google.RISCVerifier,
+	userRepo repository.UserStore,
+	identityRepo *repository.IdentityRepository,
+	sessionService *SessionService,
+	accountService *AccountService,
+	appleTokens *AppleTokenService,
+	eventRepo repository.RedisWebhookEventRepository,
+) *WebhookService {
+	return &WebhookService{
+		appleVerifier:      appleVerifier,
+		riscVerifier:       riscVerifier,
+		userRepository:     userRepo,
+		identityRepository: identityRepo,
+		sessionService:     sessionService,
+		accountService:     accountService,
+		appleTokens:        appleTokens,
+		eventRepository:    eventRepo,
+	}
+}
+
+// HandleAppleNotification verifies and applies a Sign in with Apple server-to-server notification
+// Notifications for unknown users and unknown event types are acknowledged and ignored,
+// and so are notifications that were already processed or are too old to be applied
+func (s *WebhookService) HandleAppleNotification(ctx context.Context, payload string) error {
+	event, err := s.appleVerifier.VerifyNotification(payload)
+	if errors.Is(err, apple.ErrNotificationStale) {
+		// Signed by Apple but too old to tell from a replay: acknowledged so Apple stops retrying
+		logger.SecurityEvent("webhook_event_stale", zap.String("source", webhookSourceApple), zap.Error(err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+
+	claimed, err := s.claimEvent(ctx, webhookSourceApple, event.ID, s.appleVerifier.NotificationMaxAge())
+	if err != nil || !claimed {
+		return err
+	}
+	if err := s.applyAppleNotification(ctx, event); err != nil {
+		s.releaseEvent(ctx, webhookSourceApple, event.ID)
+		return err
+	}
+
+	return nil
+}
+
+// applyAppleNotification applies the event of a server-to-server notification
+func (s *WebhookService) applyAppleNotification(ctx context.Context, event *apple.NotificationEvent) error {
+
+	user, err := s.userRepository.GetByIdentity(ctx, model.ProviderApple, event.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		log.Printf("Ignoring Apple %s notification for unknown user", event.Type)
+		return nil
+	}
+
+	switch event.Type {
+	case apple.NotificationConsentRevoked:
+		// The user stopped using Sign in with Apple for this app: end every session.
+		// Apple has already invalidated its tokens, so the stored one is only discarded.
+		logger.SecurityEvent("apple_consent_revoked", zap.Int64("user_id", user.ID))
+		if err := s.sessionService.RevokeAllUserSessions(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		s.discardAppleTokens(ctx, user.ID)
+
+	case apple.NotificationAccountDelete:
+		logger.SecurityEvent("apple_account_deleted", zap.Int64("user_id", user.ID))
+		s.discardAppleTokens(ctx, user.ID)
+		err := s.accountService.DeleteUser(ctx, user.ID)
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			return fmt.Errorf("failed to delete account: %w", err)
+		}
+
+	case apple.NotificationEmailDisabled, apple.NotificationEmailEnabled:
+		deliverable := event.Type == apple.NotificationEmailEnabled
+		if err := s.userRepository.SetEmailDeliverable(ctx, user.ID, deliverable); err != nil {
+			return fmt.Errorf("failed to update email deliverability: %w", err)
+		}
+
+	default:
+		log.Printf("Ignoring unsupported Apple notification type %q", event.Type)
+	}
+
+	return nil
+}
+
+// HandleGoogleSecurityEvent verifies and applies a Google Cross-Account Protection security event token
+// Events for unknown users and unsupported event types are acknowledged and ignored,
+// and so are tokens that were already processed or are too old to be applied
+func (s *WebhookService) HandleGoogleSecurityEvent(ctx context.Context, token string) error {
+	claims, err := s.riscVerifier.VerifySecurityEvent(token)
+	if errors.Is(err, google.ErrSecurityEventStale) {
+		// Signed by Google but too old to tell from a replay: acknowledged so Google stops retrying
+		logger.SecurityEvent("webhook_event_stale", zap.String("source", webhookSourceGoogleRISC), zap.Error(err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+
+	claimed, err := s.claimEvent(ctx, webhookSourceGoogleRISC, claims.ID, s.riscVerifier.MaxAge())
+	if err != nil || !claimed {
+		return err
+	}
+
+	for eventType, event := range claims.Events {
+		if err := s.applyGoogleSecurityEvent(ctx, eventType, event); err != nil {
+			s.releaseEvent(ctx, webhookSourceGoogleRISC, claims.ID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyGoogleSecurityEvent applies a single event of a security event token
+func (s *WebhookService) applyGoogleSecurityEvent(ctx context.Context, eventType string, event google.SecurityEvent) error {
+	// Verification events are sent when testing the stream configuration
+	if eventType == google.EventVerification {
+		log.Printf("Received Google RISC verification event (state %q)", event.State)
+		return nil
+	}
+
+	if event.Subject.Subject == "" {
+		log.Printf("Ignoring Google RISC event %s without subject", eventType)
+		return nil
+	}
+
+	user, err := s.userRepository.GetByIdentity(ctx, model.ProviderGoogle, event.Subject.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		log.Printf("Ignoring Google RISC event %s for unknown user", eventType)
+		return nil
+	}
+
+	switch eventType {
+	case google.EventSessionsRevoked, google.EventTokensRevoked:
+		logger.SecurityEvent("google_sessions_revoked",
+			zap.Int64("user_id", user.ID),
+			zap.String("risc_event", eventType),
+		)
+		if err := s.sessionService.RevokeAllUserSessions(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+	case google.EventAccountDisabled:
+		// The Google account may be in the hands of an attacker: end every session
+		// and refuse Google sign-in until Google re-enables the account
+		logger.SecurityEvent("google_account_disabled",
+			zap.Int64("user_id", user.ID),
+			zap.String("reason", event.Reason),
+		)
+		if err := s.sessionService.RevokeAllUserSessions(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if err := s.identityRepository.SetDisabled(ctx, model.ProviderGoogle, event.Subject.Subject, true); err != nil {
+			return fmt.Errorf("failed to lock google sign-in: %w", err)
+		}
+
+	case google.EventAccountEnabled:
+		logger.SecurityEvent("google_account_enabled", zap.Int64("user_id", user.ID))
+		if err := s.identityRepository.SetDisabled(ctx, model.ProviderGoogle, event.Subject.Subject, false); err != nil {
+			return fmt.Errorf("failed to unlock google sign-in: %w", err)
+		}
+
+	default:
+		// token-revoked and credential-change-required concern Google tokens
+		// and passwords, neither of which this service stores
+		log.Printf("Ignoring unsupported Google RISC event %s", eventType)
+	}
+
+	return nil
+}
+
+// claimEvent records a verified event as processed, reporting false if it already was
+// Providers only send fresh events (within maxAge), so IDs are remembered for twice as long:
+// a replay is then either a duplicate or rejected as stale.
+// Fails closed: a replay would re-apply the event, so without Redis the provider has to retry.
+func (s *WebhookService) claimEvent(ctx context.Context, source, eventID string, maxAge time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookEventTimeout)
+	defer cancel()
+
+	claimed, err := s.eventRepository.ClaimEvent(ctx, source, eventID, 2*maxAge)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for replayed event: %w", err)
+	}
+	if !claimed {
+		logger.SecurityEvent("webhook_event_replayed",
+			zap.String("source", source),
+			zap.String("event_id", eventID),
+		)
+	}
+	return claimed, nil
+}
+
+// releaseEvent forgets an event that could not be applied, so the provider's redelivery is processed
+func (s *WebhookService) releaseEvent(ctx context.Context, source, eventID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookEventTimeout)
+	defer cancel()
+
+	if err := s.eventRepository.ReleaseEvent(ctx, source, eventID); err != nil {
+		log.Printf("Failed to release %s event %s: %v", source, eventID, err)
+	}
+}
+
+// discardAppleTokens deletes the user's stored Apple refresh token, if token storage is configured
+// Failures are logged: the token is unusable anyway and is removed with the user row
+func (s *WebhookService) discardAppleTokens(ctx context.Context, userID int64) {
+	if s.appleTokens == nil {
+		return
+	}
+
+	if err := s.appleTokens.DiscardTokens(ctx, userID); err != nil {
+		log.Printf("Failed to discard Apple tokens for user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/Hamid207/ai-code-test1/internal/repository"
+	"github.com/Hamid207/ai-code-test1/pkg/apple"
+	"github.com/Hamid207/ai-code-test1/pkg/google"
+	"github.com/Hamid207/ai-code-test1/pkg/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testAppleClientID  = "com.example.app"
+	testGoogleIssuer   = "https://accounts.google.com/"
+	testGoogleClientID = "123.apps.googleusercontent.com"
+	testKeyID          = "test-key"
+)
+
+// errAnyError matches any non-nil error in the webhook tests
+var errAnyError = errors.New("any error")
+
+// fakeWebhookEvents remembers claimed event IDs in memory, or fails every claim with err
+type fakeWebhookEvents struct {
+	claimed map[string]bool
+	claims  int
+	err     error
+}
+
+func (r *fakeWebhookEvents) ClaimEvent(ctx context.Context, source, eventID string, ttl time.Duration) (bool, error) {
+	r.claims++
+	if r.err != nil {
+		return false, r.err
+	}
+	key := source + ":" + eventID
+	if r.claimed[key] {
+		return false, nil
+	}
+	r.claimed[key] = true
+	return true, nil
+}
+
+func (r *fakeWebhookEvents) ReleaseEvent(ctx context.Context, source, eventID string) error {
+	delete(r.claimed, source+":"+eventID)
+	return nil
+}
+
+// webhookUsers resolves every identity to one user; lookups fail with errs, one per call, while any are left
+type webhookUsers struct {
+	repository.UserStore
+	user        *model.User
+	errs        []error
+	deliverable []bool
+}
+
+func (s *webhookUsers) GetByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return s.user, nil
+}
+
+func (s *webhookUsers) SetEmailDeliverable(ctx context.Context, id int64, deliverable bool) error {
+	s.deliverable = append(s.deliverable, deliverable)
+	return nil
+}
+
+// newTestJWKS serves the public half of a new RSA key as a JWKS and returns the key and the JWKS URL
+func newTestJWKS(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	t.Cleanup(server.Close)
+	return key, server.URL
+}
+
+// signRS256 signs claims with key, as identity providers sign their webhook payloads
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims gojwt.Claims) string {
+	t.Helper()
+
+	token := gojwt.NewWithClaims(gojwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// webhookDeliveryTest describes deliveries of the same signed event and their expected outcome
+type webhookDeliveryTest struct {
+	name       string
+	age        time.Duration // How long before the deliveries the event was issued
+	forged     bool          // Signed with a key the provider does not publish
+	claimErr   error         // Redis failure of replay protection
+	lookupErrs []error       // Failures applying the event, one per delivery
+	wantErrs   []error       // One per delivery; errAnyError matches any error
+	wantClaims int
+	wantApply  int
+}
+
+// webhookDeliveryTests covers freshness and replay protection; every source must behave the same
+var webhookDeliveryTests = []webhookDeliveryTest{
+	{
+		name:       "redelivery is applied once",
+		wantErrs:   []error{nil, nil},
+		wantClaims: 2,
+		wantApply:  1,
+	},
+	{
+		name:     "stale event is acknowledged without being applied",
+		age:      73 * time.Hour,
+		wantErrs: []error{nil},
+	},
+	{
+		name:     "forged event is rejected",
+		forged:   true,
+		wantErrs: []error{ErrInvalidNotification},
+	},
+	{
+		name:       "event that failed to apply is applied on redelivery",
+		lookupErrs: []error{errors.New("connection reset")},
+		wantErrs:   []error{errAnyError, nil},
+		wantClaims: 2,
+		wantApply:  1,
+	},
+	{
+		name:       "without replay protection the event is not applied",
+		claimErr:   errors.New("connection refused"),
+		wantErrs:   []error{errAnyError},
+		wantClaims: 1,
+	},
+}
+
+// checkDeliveryErr reports an unexpected result of a delivery
+func checkDeliveryErr(t *testing.T, delivery int, err, want error) {
+	t.Helper()
+
+	switch {
+	case want == nil && err != nil:
+		t.Errorf("delivery %d: unexpected error: %v", delivery, err)
+	case want == errAnyError && err == nil:
+		t.Errorf("delivery %d: expected an error", delivery)
+	case want != nil && want != errAnyError && !errors.Is(err, want):
+		t.Errorf("delivery %d: err = %v, want %v", delivery, err, want)
+	}
+}
+
+func TestWebhookService_HandleAppleNotification(t *testing.T) {
+	key, jwksURL := newTestJWKS(t)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range webhookDeliveryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &fakeWebhookEvents{claimed: make(map[string]bool), err: tt.claimErr}
+			users := &webhookUsers{user: &model.User{ID: testUserID}, errs: tt.lookupErrs}
+			verifier := apple.NewVerifier(testAppleClientID).WithKeysURL(jwksURL)
+			s := NewWebhookService(verifier, nil, users, nil, nil, nil, nil, events)
+
+			signer := key
+			if tt.forged {
+				signer = forger
+			}
+			payload := signRS256(t, signer, &apple.NotificationClaims{
+				RegisteredClaims: gojwt.RegisteredClaims{
+					Issuer:   "https://appleid.apple.com",
+					Audience: gojwt.ClaimStrings{testAppleClientID},
+					ID:       "notification-1",
+					IssuedAt: gojwt.NewNumericDate(time.Now().Add(-tt.age)),
+				},
+				Events: `{"type":"email-disabled","sub":"001234.abcd","email":"x@privaterelay.appleid.com"}`,
+			})
+
+			for i, want := range tt.wantErrs {
+				checkDeliveryErr(t, i+1, s.HandleAppleNotification(context.Background(), payload), want)
+			}
+			if events.claims != tt.wantClaims {
+				t.Errorf("claims = %d, want %d", events.claims, tt.wantClaims)
+			}
+			if len(users.deliverable) != tt.wantApply {
+				t.Errorf("applied %d times, want %d", len(users.deliverable), tt.wantApply)
+			}
+		})
+	}
+}
+
+func TestWebhookService_HandleGoogleSecurityEvent(t *testing.T) {
+	key, jwksURL := newTestJWKS(t)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range webhookDeliveryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &fakeWebhookEvents{claimed: make(map[string]bool), err: tt.claimErr}
+			users := &webhookUsers{user: &model.User{ID: testUserID}, errs: tt.lookupErrs}
+			blacklist := &fakeBlacklist{}
+			tokenService := jwt.NewTokenService(jwt.Config{SecretKey: "test-secret-key-of-at-least-32-bytes"})
+			sessions := NewSessionService(newFakeRefreshTokenStore(), blacklist, tokenService)
+			verifier := google.NewRISCVerifier(testGoogleIssuer, jwksURL, testGoogleClientID)
+			s := NewWebhookService(nil, verifier, users, nil, sessions, nil, nil, events)
+
+			signer := key
+			if tt.forged {
+				signer = forger
+			}
+			token := signRS256(t, signer, &google.SecurityEventClaims{
+				RegisteredClaims: gojwt.RegisteredClaims{
+					Issuer:   testGoogleIssuer,
+					Audience: gojwt.ClaimStrings{testGoogleClientID},
+					ID:       "event-1",
+					IssuedAt: gojwt.NewNumericDate(time.Now().Add(-tt.age)),
+				},
+				Events: map[string]google.SecurityEvent{
+					google.EventSessionsRevoked: {Subject: google.SecurityEventSubject{
+						SubjectType: "iss-sub",
+						Issuer:      testGoogleIssuer,
+						Subject:     "1234567890",
+					}},
+				},
+			})
+
+			for i, want := range tt.wantErrs {
+				checkDeliveryErr(t, i+1, s.HandleGoogleSecurityEvent(context.Background(), token), want)
+			}
+			if events.claims != tt.wantClaims {
+				t.Errorf("claims = %d, want %d", events.claims, tt.wantClaims)
+			}
+			if len(blacklist.users) != tt.wantApply {
+				t.Errorf("applied %d times, want %d", len(blacklist.users), tt.wantApply)
+			}
+		})
+	}
+}
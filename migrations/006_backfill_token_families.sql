@@ -0,0 +1,13 @@
+-- Backfill token families for refresh tokens issued before family tracking
+-- Every pre-existing token becomes its own family, so reuse detection
+-- and session revocation work uniformly for old and new tokens
+UPDATE refresh_tokens
+SET token_family = md5(random()::text || id::text)
+WHERE token_family IS NULL;
+
+-- New tokens always carry a family from now on
+ALTER TABLE refresh_tokens ALTER COLUMN token_family SET NOT NULL;
+
+-- Create partial index on active tokens per family for family-wide revocation
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_active ON refresh_tokens(token_family)
+WHERE revoked_at IS NULL;
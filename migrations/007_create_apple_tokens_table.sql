@@ -0,0 +1,17 @@
+-- Create apple_tokens table
+-- Stores the Apple refresh token obtained by redeeming the authorization code at sign-in.
+-- Needed to revoke Apple tokens on account deletion and to check the account is still linked.
+CREATE TABLE IF NOT EXISTS apple_tokens (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_refresh_token TEXT NOT NULL,  -- AES-256-GCM encrypted, never stored in plaintext
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_validated_at TIMESTAMP  -- Last successful check against Apple's token endpoint
+);
+
+-- Create trigger to automatically update updated_at on row update
+CREATE TRIGGER update_apple_tokens_updated_at BEFORE UPDATE ON apple_tokens
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comment for documentation
+COMMENT ON COLUMN apple_tokens.encrypted_refresh_token IS 'Apple refresh token encrypted with APPLE_TOKEN_ENCRYPTION_KEY';
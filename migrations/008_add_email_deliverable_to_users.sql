@@ -0,0 +1,7 @@
+-- Add email_deliverable column to users table
+-- Apple private relay addresses stop forwarding mail when the user disables it in their Apple ID settings.
+-- Apple reports this with email-disabled / email-enabled server-to-server notifications.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_deliverable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Add comment for documentation
+COMMENT ON COLUMN users.email_deliverable IS 'False while mail forwarding is disabled for an Apple private relay address';
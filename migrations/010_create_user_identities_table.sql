@@ -0,0 +1,24 @@
+-- Create user_identities table
+-- Links users to logins at generic OpenID Connect providers (Microsoft Entra, Auth0, Keycloak, GitLab, ...).
+-- A user can hold one identity per provider; each provider account belongs to exactly one user.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,   -- Provider name from OIDC_PROVIDERS
+    subject VARCHAR(255) NOT NULL,   -- ID token "sub" claim
+    email VARCHAR(255),              -- Email asserted by the provider when the identity was linked
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    CONSTRAINT user_identities_provider_subject_unique UNIQUE (provider, subject),
+    CONSTRAINT user_identities_user_provider_unique UNIQUE (user_id, provider)
+);
+
+-- The UNIQUE constraints index (provider, subject) and (user_id, provider),
+-- which also serves lookups of a user's identities
+
+-- Users of generic providers have neither apple_id nor google_id
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_auth_provider;
+
+-- Add comment for documentation
+COMMENT ON TABLE user_identities IS 'Logins at generic OpenID Connect providers linked to a user';
@@ -0,0 +1,28 @@
+-- Move Apple and Google logins from users columns into user_identities
+-- Every provider, including Apple and Google, is now linked through user_identities,
+-- so users no longer need a column per provider.
+
+-- Step 1: Track provider-reported account lockouts (e.g. Google Cross-Account Protection) per identity
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+COMMENT ON COLUMN user_identities.disabled_at IS 'When the provider reported the account disabled; sign-in with this identity is refused until cleared';
+
+-- Step 2: Copy existing logins. Emails were verified by the provider at sign-in.
+INSERT INTO user_identities (user_id, provider, subject, email, email_verified, linked_at)
+SELECT id, 'apple', apple_id, email, TRUE, created_at
+FROM users
+WHERE apple_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_identities (user_id, provider, subject, email, email_verified, linked_at)
+SELECT id, 'google', google_id, email, TRUE, created_at
+FROM users
+WHERE google_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- Step 3: Drop the per-provider columns (their UNIQUE indexes are dropped with them)
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_auth_provider;
+ALTER TABLE users DROP COLUMN IF EXISTS apple_id;
+ALTER TABLE users DROP COLUMN IF EXISTS google_id;
+
+COMMENT ON TABLE user_identities IS 'Logins at identity providers (apple, google and OIDC_PROVIDERS) linked to a user';
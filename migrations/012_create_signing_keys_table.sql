@@ -0,0 +1,20 @@
+-- Create signing_keys table
+-- Holds the key ring that signs and verifies access and refresh tokens, shared by all instances.
+-- The most recently activated key signs new tokens; older keys only verify tokens they signed.
+-- New keys are published before activates_at so every instance and JWKS consumer knows them
+-- before the first token they sign.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid VARCHAR(255) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    encrypted_private_key TEXT NOT NULL,  -- AES-256-GCM encrypted PKCS#8 PEM, never stored in plaintext
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    activates_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP  -- Set when a newer key is added: activation of the newer key plus the longest token TTL
+);
+
+-- Create index for loading the key ring and removing retired keys
+CREATE INDEX IF NOT EXISTS idx_signing_keys_expires_at ON signing_keys(expires_at);
+
+-- Add comments for documentation
+COMMENT ON COLUMN signing_keys.encrypted_private_key IS 'Private key encrypted with JWT_KEY_ENCRYPTION_KEY';
+COMMENT ON COLUMN signing_keys.expires_at IS 'NULL while the key is the newest; the key verifies tokens until this time';
@@ -0,0 +1,12 @@
+-- Add profile columns to users
+-- Filled from identity provider claims at sign-in while empty (Apple sends the user's name
+-- only on the first sign-in) and editable by the user through PATCH /api/v1/me.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(2048),
+    ADD COLUMN IF NOT EXISTS locale VARCHAR(35);
+
+-- Add comments for documentation
+COMMENT ON COLUMN users.display_name IS 'Name shown to other users; NULL if unknown';
+COMMENT ON COLUMN users.avatar_url IS 'https URL of the profile picture; NULL if none';
+COMMENT ON COLUMN users.locale IS 'BCP 47 language tag, e.g. en-US; NULL if unknown';
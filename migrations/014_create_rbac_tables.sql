@@ -0,0 +1,56 @@
+-- Create role-based access control tables
+-- Permissions are checked by the API (RequirePermission) and defined in code, so they are
+-- only added by migrations. Roles group permissions and are managed through the admin API.
+-- Access tokens carry the user's role names (roles claim) and permissions (scope claim).
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(64) PRIMARY KEY,  -- e.g. users:read
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    system BOOLEAN NOT NULL DEFAULT FALSE,  -- Managed by migrations; cannot be changed through the API
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    granted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,  -- NULL if granted outside the API
+    granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+-- Create index for finding the users holding a role
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles(role_id);
+
+-- Seed the permissions checked by the API and the admin role holding all of them
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'View users and their roles'),
+    ('users:write', 'Change users'),
+    ('roles:read', 'View roles and permissions'),
+    ('roles:write', 'Create, change and delete roles and assign them to users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, system) VALUES
+    ('admin', 'Full access to the admin API', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, permissions.name FROM roles, permissions
+WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- Add comments for documentation
+COMMENT ON TABLE permissions IS 'Permissions checked by the API, seeded by migrations';
+COMMENT ON TABLE roles IS 'Named sets of permissions assigned to users';
+COMMENT ON COLUMN roles.system IS 'Roles seeded by migrations cannot be changed or deleted through the API';
+COMMENT ON TABLE user_roles IS 'Roles assigned to users; the first admin is assigned with SQL';
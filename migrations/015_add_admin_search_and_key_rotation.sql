@@ -0,0 +1,13 @@
+-- Support the admin API used by support staff
+-- Create index for the email prefix search of the admin API
+CREATE INDEX IF NOT EXISTS idx_users_email_pattern ON users(LOWER(email) text_pattern_ops);
+
+-- Seed the permission for rotating token signing keys through the admin API
+INSERT INTO permissions (name, description) VALUES
+    ('keys:rotate', 'Rotate the token signing key')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, 'keys:rotate' FROM roles
+WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+-- Record why each refresh token was revoked
+-- Only a rotated token presented again signals a stolen copy; tokens of ended sessions
+-- are presented again by clients retrying a refresh after logout.
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS revoked_reason VARCHAR(16);
+
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS check_refresh_token_revoked_reason;
+ALTER TABLE refresh_tokens ADD CONSTRAINT check_refresh_token_revoked_reason
+    CHECK (revoked_reason IN ('rotated', 'logout'));
+
+-- Tokens revoked so far cannot be told apart; treat them as ended sessions, so presenting one
+-- again is only rejected instead of revoking a session that may still be in use
+UPDATE refresh_tokens
+SET revoked_reason = 'logout'
+WHERE revoked_at IS NOT NULL AND revoked_reason IS NULL;
+
+-- Add comment for documentation
+COMMENT ON COLUMN refresh_tokens.revoked_reason IS 'rotated (exchanged for a new token) or logout (session ended); NULL while active';
@@ -0,0 +1,21 @@
+-- Add account status to users
+-- Every status other than active blocks sign-in, token refresh and API access; setting one
+-- also ends every session. A status with an expiry reverts to active once it has passed.
+-- Managed by support staff through the admin API.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason TEXT,
+    ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_user_status;
+ALTER TABLE users ADD CONSTRAINT check_user_status
+    CHECK (status IN ('active', 'suspended', 'banned', 'pending_deletion'));
+
+-- Create index for listing restricted users
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status) WHERE status <> 'active';
+
+-- Add comments for documentation
+COMMENT ON COLUMN users.status IS 'active, suspended, banned or pending_deletion; only active users can sign in';
+COMMENT ON COLUMN users.status_reason IS 'Why the status was set, for support staff';
+COMMENT ON COLUMN users.status_expires_at IS 'When a non-active status reverts to active; NULL if it does not expire';
@@ -0,0 +1,258 @@
+package apple
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultTokenURL is Apple's token endpoint (code exchange and refresh token validation)
+	DefaultTokenURL = "https://appleid.apple.com/auth/token"
+
+	// DefaultRevokeURL is Apple's token revocation endpoint
+	DefaultRevokeURL = "https://appleid.apple.com/auth/revoke"
+)
+
+// ErrInvalidGrant is returned when Apple rejects an authorization code or refresh token
+// For refresh tokens this means the user revoked access or deleted their Apple ID link
+var ErrInvalidGrant = errors.New("invalid grant")
+
+// TokenTypeHint tells Apple which kind of token is being revoked
+type TokenTypeHint string
+
+const (
+	TokenTypeRefreshToken TokenTypeHint = "refresh_token"
+	TokenTypeAccessToken  TokenTypeHint = "access_token"
+)
+
+// ClientSecretSource provides the client_secret sent to Apple's token endpoints
+type ClientSecretSource interface {
+	ClientSecret() (string, error)
+}
+
+// StaticClientSecret is a pre-generated client secret JWT
+type StaticClientSecret string
+
+// ClientSecret returns the pre-generated secret
+func (s StaticClientSecret) ClientSecret() (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("client secret is not configured")
+	}
+	return string(s), nil
+}
+
+// Revoker revokes Apple tokens
+// Implemented by Client; tests can substitute a fake
+type Revoker interface {
+	RevokeToken(ctx context.Context, token string, hint TokenTypeHint) error
+}
+
+// TokenClient redeems, validates and revokes Apple tokens
+// Implemented by Client; tests can substitute a fake
+type TokenClient interface {
+	Revoker
+	ExchangeCode(ctx context.Context, code, redirectURI string) (*TokenResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error)
+}
+
+// TokenResponse represents the response from Apple's token endpoint
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"` // Only returned for authorization code exchange
+	IDToken      string `json:"id_token"`
+}
+
+// IDTokenSubject returns the subject (Apple user ID) of the returned ID token
+// The signature is not verified: the token was received directly from Apple over TLS
+func (t *TokenResponse) IDTokenSubject() (string, error) {
+	if t.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+
+	var claims jwt.RegisteredClaims
+	_, _, err := jwt.NewParser().ParseUnverified(t.IDToken, &claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse id_token: %w", err)
+	}
+
+	return claims.Subject, nil
+}
+
+// ClientConfig holds configuration for the Apple REST API client
+type ClientConfig struct {
+	ClientID     string
+	ClientSecret ClientSecretSource
+	TokenURL     string       // Defaults to DefaultTokenURL
+	RevokeURL    string       // Defaults to DefaultRevokeURL
+	HTTPClient   *http.Client // Defaults to a client with a 10 second timeout
+}
+
+// Client calls Apple's Sign in with Apple REST endpoints
+type Client struct {
+	clientID     string
+	clientSecret ClientSecretSource
+	tokenURL     string
+	revokeURL    string
+	httpClient   *http.Client
+}
+
+// appleErrorResponse represents an error returned by Apple's REST endpoints
+type appleErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewClient creates a new Apple REST API client
+func NewClient(cfg ClientConfig) *Client {
+	client := &Client{
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		tokenURL:     cfg.TokenURL,
+		revokeURL:    cfg.RevokeURL,
+		httpClient:   cfg.HTTPClient,
+	}
+
+	if client.tokenURL == "" {
+		client.tokenURL = DefaultTokenURL
+	}
+	if client.revokeURL == "" {
+		client.revokeURL = DefaultRevokeURL
+	}
+	if client.httpClient == nil {
+		client.httpClient = &http.Client{
+			Timeout: 10 * time.Second, // Prevent hanging requests
+		}
+	}
+
+	return client
+}
+
+// ExchangeCode redeems an authorization code for Apple access, refresh and ID tokens
+// redirectURI must match the one used to obtain the code (empty for native apps)
+func (c *Client) ExchangeCode(ctx context.Context, code, redirectURI string) (*TokenResponse, error) {
+	form := url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {code},
+	}
+	if redirectURI != "" {
+		form.Set("redirect_uri", redirectURI)
+	}
+
+	response, err := c.requestToken(ctx, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	if response.RefreshToken == "" {
+		return nil, fmt.Errorf("token response has no refresh_token")
+	}
+
+	return response, nil
+}
+
+// RefreshToken validates an Apple refresh token by obtaining a new access token
+// Returns ErrInvalidGrant if the user no longer authorizes this app
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+
+	response, err := c.requestToken(ctx, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	return response, nil
+}
+
+// requestToken calls Apple's token endpoint with the client credentials added to form
+func (c *Client) requestToken(ctx context.Context, form url.Values) (*TokenResponse, error) {
+	secret, err := c.clientSecret.ClientSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client secret: %w", err)
+	}
+
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", secret)
+
+	body, err := c.postForm(ctx, c.tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var response TokenResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// RevokeToken invalidates an Apple refresh or access token
+// Required when a user deletes their account
+func (c *Client) RevokeToken(ctx context.Context, token string, hint TokenTypeHint) error {
+	secret, err := c.clientSecret.ClientSecret()
+	if err != nil {
+		return fmt.Errorf("failed to get client secret: %w", err)
+	}
+
+	form := url.Values{
+		"client_id":       {c.clientID},
+		"client_secret":   {secret},
+		"token":           {token},
+		"token_type_hint": {string(hint)},
+	}
+
+	_, err = c.postForm(ctx, c.revokeURL, form)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// postForm sends a form-encoded POST request and returns the response body
+func (c *Client) postForm(ctx context.Context, endpoint string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Limit response body size to prevent memory exhaustion attacks
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var appleErr appleErrorResponse
+		if json.Unmarshal(body, &appleErr) == nil && appleErr.Error != "" {
+			if appleErr.Error == "invalid_grant" {
+				return nil, ErrInvalidGrant
+			}
+			return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, appleErr.Error)
+		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
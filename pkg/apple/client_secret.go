@@ -0,0 +1,118 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// clientSecretTTL is the lifetime of generated client secrets
+	// Apple accepts up to 6 months; short-lived secrets limit the impact of a leak
+	clientSecretTTL = 24 * time.Hour
+
+	// clientSecretRenewBefore regenerates the cached secret before it expires
+	clientSecretRenewBefore = 5 * time.Minute
+)
+
+// ClientSecretGenerator builds the ES256-signed client secret JWT required by Apple's REST API
+// Implements ClientSecretSource; the signed secret is cached until shortly before it expires
+type ClientSecretGenerator struct {
+	teamID     string
+	clientID   string
+	keyID      string
+	privateKey *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	secret    string
+	expiresAt time.Time
+}
+
+// NewClientSecretGenerator creates a generator from the contents of a .p8 private key
+// teamID is the Apple Developer Team ID, keyID the ID of the Sign in with Apple key
+func NewClientSecretGenerator(teamID, clientID, keyID string, privateKeyPEM []byte) (*ClientSecretGenerator, error) {
+	if teamID == "" || clientID == "" || keyID == "" {
+		return nil, fmt.Errorf("team ID, client ID and key ID are required")
+	}
+
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientSecretGenerator{
+		teamID:     teamID,
+		clientID:   clientID,
+		keyID:      keyID,
+		privateKey: privateKey,
+	}, nil
+}
+
+// NewClientSecretGeneratorFromFile creates a generator from a .p8 private key file
+func NewClientSecretGeneratorFromFile(teamID, clientID, keyID, privateKeyPath string) (*ClientSecretGenerator, error) {
+	privateKeyPEM, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return NewClientSecretGenerator(teamID, clientID, keyID, privateKeyPEM)
+}
+
+// ClientSecret returns a valid client secret, signing a new one when needed
+func (g *ClientSecretGenerator) ClientSecret() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.secret != "" && time.Until(g.expiresAt) > clientSecretRenewBefore {
+		return g.secret, nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(clientSecretTTL)
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    g.teamID,
+		Subject:   g.clientID,
+		Audience:  jwt.ClaimStrings{appleIssuer},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = g.keyID
+
+	secret, err := token.SignedString(g.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client secret: %w", err)
+	}
+
+	g.secret = secret
+	g.expiresAt = expiresAt
+
+	return secret, nil
+}
+
+// parsePrivateKey parses a PKCS#8 PEM-encoded P-256 private key (Apple's .p8 format)
+func parsePrivateKey(privateKeyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an ECDSA key")
+	}
+
+	return ecKey, nil
+}
@@ -0,0 +1,116 @@
+package apple
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultNotificationMaxAge is how long after it was issued a server-to-server notification is applied
+	// Apple retries undelivered notifications, so the window covers a receiver outage of a few days
+	DefaultNotificationMaxAge = 72 * time.Hour
+
+	// notificationClockSkew is the leeway allowed for notifications issued in the future
+	notificationClockSkew = time.Minute
+)
+
+// ErrNotificationStale is returned for a correctly signed notification issued outside the accepted window
+// It is not a forgery: receivers should acknowledge the notification without applying it
+var ErrNotificationStale = errors.New("notification is stale")
+
+// NotificationType identifies a Sign in with Apple server-to-server notification
+type NotificationType string
+
+const (
+	// NotificationEmailDisabled means the user stopped forwarding mail from their private relay address
+	NotificationEmailDisabled NotificationType = "email-disabled"
+
+	// NotificationEmailEnabled means the user resumed forwarding mail from their private relay address
+	NotificationEmailEnabled NotificationType = "email-enabled"
+
+	// NotificationConsentRevoked means the user stopped using Sign in with Apple for this app
+	NotificationConsentRevoked NotificationType = "consent-revoked"
+
+	// NotificationAccountDelete means the user deleted their Apple ID
+	NotificationAccountDelete NotificationType = "account-delete"
+)
+
+// NotificationClaims represents the claims of a server-to-server notification payload
+// Events is a JSON-encoded NotificationEvent
+type NotificationClaims struct {
+	jwt.RegisteredClaims
+	Events string `json:"events"`
+}
+
+// NotificationEvent represents the event carried by a server-to-server notification
+type NotificationEvent struct {
+	ID        string           `json:"-"` // jti of the notification that carried the event
+	Type      NotificationType `json:"type"`
+	Subject   string           `json:"sub"`        // Apple user ID
+	Email     string           `json:"email"`      // Only present for email events
+	EventTime int64            `json:"event_time"` // Milliseconds since epoch
+}
+
+// WithNotificationMaxAge sets how long after it was issued a notification is applied
+// Receivers remember notification IDs for longer than this to drop replays
+func (v *Verifier) WithNotificationMaxAge(maxAge time.Duration) *Verifier {
+	v.notificationMaxAge = maxAge
+	return v
+}
+
+// NotificationMaxAge returns how long after it was issued a notification is applied
+func (v *Verifier) NotificationMaxAge() time.Duration {
+	return v.notificationMaxAge
+}
+
+// VerifyNotification verifies a server-to-server notification payload and returns its event
+// The payload is signed with the same keys as ID tokens and addressed to this app's client ID.
+// Notifications issued more than NotificationMaxAge ago fail with ErrNotificationStale; replays
+// within that window pass, so callers must drop events whose ID they have already seen.
+func (v *Verifier) VerifyNotification(payload string) (*NotificationEvent, error) {
+	token, err := v.parse(payload, &NotificationClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification: %w", err)
+	}
+
+	claims, ok := token.Claims.(*NotificationClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid notification claims")
+	}
+
+	if err := v.validateIssuerAndAudience(claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+
+	// Validate freshness; the notification ID is needed to detect replays
+	if claims.IssuedAt == nil {
+		return nil, errors.New("notification missing iat")
+	}
+	age := time.Since(claims.IssuedAt.Time)
+	if age > v.notificationMaxAge || age < -notificationClockSkew {
+		return nil, fmt.Errorf("%w: issued at %s", ErrNotificationStale, claims.IssuedAt.Time)
+	}
+	if claims.ID == "" {
+		return nil, errors.New("notification missing jti")
+	}
+
+	if claims.Events == "" {
+		return nil, errors.New("notification missing events claim")
+	}
+
+	var event NotificationEvent
+	if err := json.Unmarshal([]byte(claims.Events), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification event: %w", err)
+	}
+
+	if event.Type == "" || event.Subject == "" {
+		return nil, errors.New("notification event missing type or subject")
+	}
+	event.ID = claims.ID
+
+	return &event, nil
+}
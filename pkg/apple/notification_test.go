@@ -0,0 +1,187 @@
+package apple
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "com.example.app"
+	testKeyID    = "test-key"
+)
+
+// newTestKeys serves the public half of a new RSA key as a JWKS and returns the key and the JWKS URL
+func newTestKeys(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	t.Cleanup(server.Close)
+	return key, server.URL
+}
+
+// signNotification signs notification claims as Apple does
+func signNotification(t *testing.T, key *rsa.PrivateKey, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// notificationClaims returns the claims of a consent-revoked notification issued at issuedAt
+func notificationClaims(issuedAt time.Time) *NotificationClaims {
+	return &NotificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   appleIssuer,
+			Audience: jwt.ClaimStrings{testClientID},
+			ID:       "notification-1",
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+		Events: `{"type":"consent-revoked","sub":"001234.abcd","event_time":1700000000000}`,
+	}
+}
+
+func TestVerifyNotification(t *testing.T) {
+	key, jwksURL := newTestKeys(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		maxAge    time.Duration // Default window if zero
+		claims    func(claims *NotificationClaims)
+		signer    *rsa.PrivateKey
+		wantStale bool
+		wantErr   bool
+	}{
+		{name: "fresh"},
+		{
+			name: "near the end of the window",
+			claims: func(c *NotificationClaims) {
+				c.IssuedAt = jwt.NewNumericDate(now.Add(-DefaultNotificationMaxAge + time.Minute))
+			},
+		},
+		{
+			name: "older than the window",
+			claims: func(c *NotificationClaims) {
+				c.IssuedAt = jwt.NewNumericDate(now.Add(-DefaultNotificationMaxAge - time.Minute))
+			},
+			wantStale: true,
+		},
+		{
+			name:      "older than a configured window",
+			maxAge:    time.Hour,
+			claims:    func(c *NotificationClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour)) },
+			wantStale: true,
+		},
+		{
+			name:   "issued in the future within the clock skew",
+			claims: func(c *NotificationClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(30 * time.Second)) },
+		},
+		{
+			name:      "issued further in the future",
+			claims:    func(c *NotificationClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour)) },
+			wantStale: true,
+		},
+		{
+			name:    "missing iat",
+			claims:  func(c *NotificationClaims) { c.IssuedAt = nil },
+			wantErr: true,
+		},
+		{
+			name:    "missing jti",
+			claims:  func(c *NotificationClaims) { c.ID = "" },
+			wantErr: true,
+		},
+		{
+			name:    "addressed to another app",
+			claims:  func(c *NotificationClaims) { c.Audience = jwt.ClaimStrings{"com.example.other"} },
+			wantErr: true,
+		},
+		{
+			name:    "not issued by Apple",
+			claims:  func(c *NotificationClaims) { c.Issuer = "https://example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "signed with another key",
+			signer:  otherKey,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier(testClientID).WithKeysURL(jwksURL)
+			if tt.maxAge > 0 {
+				verifier.WithNotificationMaxAge(tt.maxAge)
+			}
+			claims := notificationClaims(now)
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			signer := key
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+
+			event, err := verifier.VerifyNotification(signNotification(t, signer, claims))
+			if got := errors.Is(err, ErrNotificationStale); got != tt.wantStale {
+				t.Fatalf("err = %v, want stale %t", err, tt.wantStale)
+			}
+			if tt.wantStale {
+				return
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.ID != claims.ID || event.Type != NotificationConsentRevoked || event.Subject != "001234.abcd" {
+				t.Errorf("event = %+v", event)
+			}
+		})
+	}
+}
@@ -1,17 +1,13 @@
 package apple
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"math/big"
 	"net/http"
-	"sync"
 	"time"
 
+	"github.com/Hamid207/ai-code-test1/pkg/oidc"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -21,81 +17,52 @@ const (
 	maxResponseBodySize = 1024 * 1024 // 1MB limit for response body
 )
 
-// ApplePublicKeys represents Apple's public keys response
-type ApplePublicKeys struct {
-	Keys []ApplePublicKey `json:"keys"`
-}
-
-// ApplePublicKey represents a single Apple public key
-type ApplePublicKey struct {
-	Kty string `json:"kty"`
-	Kid string `json:"kid"`
-	Use string `json:"use"`
-	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
-}
+// signingMethods are the algorithms Apple signs ID tokens and notifications with
+var signingMethods = []string{"RS256"}
 
 // AppleClaims represents the claims in Apple ID token
 type AppleClaims struct {
 	jwt.RegisteredClaims
-	Email         string `json:"email"`
-	EmailVerified string `json:"email_verified"`
-	Nonce         string `json:"nonce"`
-	NonceSupported bool  `json:"nonce_supported"`
+	Email          string `json:"email"`
+	EmailVerified  string `json:"email_verified"`
+	Nonce          string `json:"nonce"`
+	NonceSupported bool   `json:"nonce_supported"`
 }
 
 // Verifier handles Apple ID token verification
 type Verifier struct {
-	clientID   string
-	httpClient *http.Client
-	mu         sync.RWMutex
-	keys       map[string]*rsa.PublicKey
-	lastFetch  time.Time
+	clientID string
+	keys     *oidc.KeySet
+
+	// How long after it was issued a server-to-server notification is applied
+	notificationMaxAge time.Duration
 }
 
 // NewVerifier creates a new Apple token verifier
 func NewVerifier(clientID string) *Verifier {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second, // Prevent hanging requests
+	}
+
 	return &Verifier{
-		clientID: clientID,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second, // Prevent hanging requests
-		},
-		keys: make(map[string]*rsa.PublicKey),
+		clientID:           clientID,
+		keys:               oidc.NewKeySet(applePublicKeyURL, httpClient),
+		notificationMaxAge: DefaultNotificationMaxAge,
 	}
 }
 
+// WithKeysURL fetches Apple's public keys from another JWKS URL, e.g. a local fake of Apple
+func (v *Verifier) WithKeysURL(jwksURL string) *Verifier {
+	v.keys = oidc.NewKeySet(jwksURL, &http.Client{
+		Timeout: 10 * time.Second, // Prevent hanging requests
+	})
+	return v
+}
+
 // VerifyIDToken verifies an Apple ID token and returns the claims
 func (v *Verifier) VerifyIDToken(idToken, expectedNonce string) (*AppleClaims, error) {
-	// Parse the token without verification first to get the kid
-	token, err := jwt.ParseWithClaims(idToken, &AppleClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing algorithm
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		// Get the key ID from token header
-		kid, ok := token.Header["kid"].(string)
-		if !ok {
-			return nil, errors.New("kid not found in token header")
-		}
-
-		// Fetch public keys if not cached or expired
-		if err := v.refreshKeysIfNeeded(); err != nil {
-			return nil, fmt.Errorf("failed to fetch public keys: %w", err)
-		}
-
-		// Get the public key for this kid (with read lock)
-		v.mu.RLock()
-		publicKey, ok := v.keys[kid]
-		v.mu.RUnlock()
-
-		if !ok {
-			return nil, fmt.Errorf("public key not found for kid: %s", kid)
-		}
-
-		return publicKey, nil
-	})
+	// Parse the token and verify its signature against Apple's public keys
+	token, err := v.parse(idToken, &AppleClaims{})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -107,21 +74,9 @@ func (v *Verifier) VerifyIDToken(idToken, expectedNonce string) (*AppleClaims, e
 		return nil, errors.New("invalid token claims")
 	}
 
-	// Validate issuer
-	if claims.Issuer != appleIssuer {
-		return nil, fmt.Errorf("invalid issuer: %s", claims.Issuer)
-	}
-
-	// Validate audience (client ID)
-	validAudience := false
-	for _, aud := range claims.Audience {
-		if aud == v.clientID {
-			validAudience = true
-			break
-		}
-	}
-	if !validAudience {
-		return nil, errors.New("invalid audience")
+	// Validate issuer and audience (client ID)
+	if err := v.validateIssuerAndAudience(claims.RegisteredClaims); err != nil {
+		return nil, err
 	}
 
 	// Validate expiration
@@ -143,110 +98,21 @@ func (v *Verifier) VerifyIDToken(idToken, expectedNonce string) (*AppleClaims, e
 	return claims, nil
 }
 
-// refreshKeysIfNeeded fetches Apple's public keys if cache is stale
-// Uses double-checked locking to prevent multiple concurrent fetches
-func (v *Verifier) refreshKeysIfNeeded() error {
-	// First check (with read lock) - fast path
-	v.mu.RLock()
-	needsRefresh := time.Since(v.lastFetch) >= 24*time.Hour || len(v.keys) == 0
-	v.mu.RUnlock()
-
-	if !needsRefresh {
-		return nil
-	}
-
-	// Acquire write lock for second check
-	v.mu.Lock()
-	defer v.mu.Unlock()
-
-	// Second check (with write lock) - prevents race condition
-	// Another goroutine may have already fetched keys while we were waiting for the lock
-	needsRefresh = time.Since(v.lastFetch) >= 24*time.Hour || len(v.keys) == 0
-	if !needsRefresh {
-		return nil
-	}
-
-	// Only one goroutine reaches here and fetches keys
-	return v.fetchPublicKeysLocked()
+// parse parses a token signed with one of Apple's published keys into claims
+func (v *Verifier) parse(token string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(token, claims, v.keys.KeyFunc(context.Background()), jwt.WithValidMethods(signingMethods))
 }
 
-// fetchPublicKeysLocked retrieves Apple's public keys
-// IMPORTANT: Caller must hold write lock (v.mu.Lock) before calling this method
-func (v *Verifier) fetchPublicKeysLocked() error {
-	resp, err := v.httpClient.Get(applePublicKeyURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch keys: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	// Limit response body size to prevent memory exhaustion attacks
-	limitedReader := io.LimitReader(resp.Body, maxResponseBodySize)
-	body, err := io.ReadAll(limitedReader)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var appleKeys ApplePublicKeys
-	if err := json.Unmarshal(body, &appleKeys); err != nil {
-		return fmt.Errorf("failed to unmarshal keys: %w", err)
+// validateIssuerAndAudience checks that a token was issued by Apple for this app
+func (v *Verifier) validateIssuerAndAudience(claims jwt.RegisteredClaims) error {
+	if claims.Issuer != appleIssuer {
+		return fmt.Errorf("invalid issuer: %s", claims.Issuer)
 	}
 
-	// Convert Apple's JWK to RSA public keys (with validation)
-	newKeys := make(map[string]*rsa.PublicKey)
-	for _, key := range appleKeys.Keys {
-		// Validate key type and algorithm
-		if key.Kty != "RSA" {
-			// Skip non-RSA keys
-			continue
-		}
-		if key.Alg != "RS256" {
-			// Skip keys with unsupported algorithm
-			continue
-		}
-
-		publicKey, err := jwkToRSAPublicKey(key)
-		if err != nil {
-			return fmt.Errorf("failed to convert key %s: %w", key.Kid, err)
+	for _, aud := range claims.Audience {
+		if aud == v.clientID {
+			return nil
 		}
-		newKeys[key.Kid] = publicKey
-	}
-
-	// Update keys and lastFetch (caller already holds write lock)
-	v.keys = newKeys
-	v.lastFetch = time.Now()
-
-	return nil
-}
-
-// jwkToRSAPublicKey converts a JWK to an RSA public key
-func jwkToRSAPublicKey(key ApplePublicKey) (*rsa.PublicKey, error) {
-	// Decode the modulus
-	nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode modulus: %w", err)
 	}
-
-	// Decode the exponent
-	eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode exponent: %w", err)
-	}
-
-	// Convert bytes to big.Int
-	n := new(big.Int).SetBytes(nBytes)
-
-	// Convert exponent bytes to int
-	var e int
-	for _, b := range eBytes {
-		e = e<<8 + int(b)
-	}
-
-	return &rsa.PublicKey{
-		N: n,
-		E: e,
-	}, nil
+	return errors.New("invalid audience")
 }
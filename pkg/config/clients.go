@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tokenClientNameRegex restricts client IDs to values safe in headers and env var names
+var tokenClientNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{1,31}$`)
+
+// TokenClientConfig overrides token lifetimes for requests sending X-Client-ID: <name>
+// Each client listed in JWT_CLIENTS is configured with JWT_CLIENT_<NAME>_ACCESS_TOKEN_TTL,
+// JWT_CLIENT_<NAME>_REFRESH_TOKEN_TTL and JWT_CLIENT_<NAME>_SECRET, where <NAME> is the upper-cased
+// client name with dashes replaced by underscores. Unset lifetimes fall back to JWT_ACCESS_TOKEN_TTL
+// and JWT_REFRESH_TOKEN_TTL. Requests must send the secret in X-Client-Secret for the overrides to apply.
+type TokenClientConfig struct {
+	Name            string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Secret          string
+}
+
+// loadTokenClients reads the overrides of each client in a comma-separated name list
+func loadTokenClients(names string) []TokenClientConfig {
+	var clients []TokenClientConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "JWT_CLIENT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		clients = append(clients, TokenClientConfig{
+			Name:            name,
+			AccessTokenTTL:  getEnvAsDuration(prefix+"ACCESS_TOKEN_TTL", 0),
+			RefreshTokenTTL: getEnvAsDuration(prefix+"REFRESH_TOKEN_TTL", 0),
+			Secret:          getEnv(prefix+"SECRET", ""),
+		})
+	}
+	return clients
+}
+
+// validateTokenLifetimes ensures default and per-client token lifetimes are usable
+func validateTokenLifetimes(accessTTL, refreshTTL time.Duration, clients []TokenClientConfig) error {
+	if accessTTL <= 0 || refreshTTL <= 0 {
+		return fmt.Errorf("JWT_ACCESS_TOKEN_TTL and JWT_REFRESH_TOKEN_TTL must be positive")
+	}
+	if accessTTL > refreshTTL {
+		return fmt.Errorf("JWT_ACCESS_TOKEN_TTL (%s) cannot exceed JWT_REFRESH_TOKEN_TTL (%s)", accessTTL, refreshTTL)
+	}
+
+	seen := make(map[string]bool)
+	for _, client := range clients {
+		if !tokenClientNameRegex.MatchString(client.Name) {
+			return fmt.Errorf("JWT client name %q must be 2-32 lowercase letters, digits or dashes", client.Name)
+		}
+		if seen[client.Name] {
+			return fmt.Errorf("JWT client %q is configured twice", client.Name)
+		}
+		seen[client.Name] = true
+
+		if len(client.Secret) < 32 {
+			return fmt.Errorf("JWT client %q: secret must be at least 32 characters", client.Name)
+		}
+
+		clientAccessTTL, clientRefreshTTL := accessTTL, refreshTTL
+		if client.AccessTokenTTL != 0 {
+			clientAccessTTL = client.AccessTokenTTL
+		}
+		if client.RefreshTokenTTL != 0 {
+			clientRefreshTTL = client.RefreshTokenTTL
+		}
+		if clientAccessTTL <= 0 || clientRefreshTTL <= 0 {
+			return fmt.Errorf("JWT client %q: token lifetimes must be positive", client.Name)
+		}
+		if clientAccessTTL > clientRefreshTTL {
+			return fmt.Errorf("JWT client %q: access token lifetime (%s) cannot exceed refresh token lifetime (%s)", client.Name, clientAccessTTL, clientRefreshTTL)
+		}
+	}
+
+	return nil
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,12 +23,85 @@ type Config struct {
 	DBMinConns     int32
 	JWTSecret      string
 	// Redis configuration
-	RedisHost        string
-	RedisPort        string
-	RedisDB          int
-	RedisPassword    string
-	RedisMaxConns    int
+	RedisHost         string
+	RedisPort         string
+	RedisDB           int
+	RedisPassword     string
+	RedisMaxConns     int
 	RedisMinIdleConns int
+
+	// Apple REST API configuration (code exchange and token revocation)
+	// The client secret is signed with the .p8 key when AppleKeyID and ApplePrivateKeyPath are set,
+	// otherwise the pre-generated AppleClientSecret is used
+	AppleClientSecret       string
+	AppleKeyID              string
+	ApplePrivateKeyPath     string
+	AppleTokenURL           string
+	AppleRevokeURL          string
+	AppleRedirectURI        string
+	AppleTokenEncryptionKey string // Base64-encoded 32-byte key for Apple refresh tokens at rest
+
+	// How often each stored Apple refresh token is checked with Apple; 0 disables the checks
+	AppleTokenValidationInterval time.Duration
+
+	// How long after it was issued a server-to-server notification is applied
+	AppleNotificationMaxAge time.Duration
+
+	// Google Cross-Account Protection (RISC) configuration
+	GoogleRISCIssuer  string
+	GoogleRISCJWKSURL string
+	GoogleRISCMaxAge  time.Duration // How long after it was issued a security event token is applied
+
+	// Generic OpenID Connect providers (OIDC_PROVIDERS)
+	OIDCProviders []OIDCProviderConfig
+
+	// Account linking: email domains for which a new login with a verified email
+	// is linked to the existing user automatically ("*" trusts every domain)
+	LinkTrustedEmailDomains []string
+
+	// Asymmetric JWT signing (JWT_SECRET then only verifies previously issued HS256 tokens)
+	JWTSigningKeyPath string // PEM private key: RSA (RS256), EC P-256 (ES256) or Ed25519 (EdDSA)
+	JWTSigningKeyID   string // kid header; defaults to the key's JWK thumbprint
+
+	// End of the window in which HS256 tokens are verified after the switch (zero: no window)
+	JWTHMACAcceptedUntil time.Time
+
+	// Signing key rotation: keys are stored encrypted in the database and rotated on schedule
+	JWTKeyEncryptionKey    string        // Base64-encoded 32-byte key; enables the database key ring
+	JWTKeyAlgorithm        string        // Algorithm of generated keys: RS256, ES256 or EdDSA
+	JWTKeyRotationInterval time.Duration // 0 disables scheduled rotation
+	JWTKeyPublishDelay     time.Duration // How long new keys are published before they sign
+
+	// Token claims and lifetimes
+	JWTIssuer          string
+	JWTAudience        string
+	JWTAccessTokenTTL  time.Duration
+	JWTRefreshTokenTTL time.Duration
+	JWTClients         []TokenClientConfig // Per-client lifetime overrides (JWT_CLIENTS)
+
+	// End of the window in which version 1 token claims are accepted (zero: no end)
+	JWTLegacyClaimsUntil time.Time
+
+	// Rate limiting: one policy per group of routes (RATE_LIMIT_<NAME>_*)
+	RateLimitPolicies []RateLimitPolicyConfig
+
+	// Brute-force protection for sign-in and token refresh (a threshold of 0 disables it)
+	LockoutIPThreshold      int           // Failed attempts per IP address before it is locked out
+	LockoutSubjectThreshold int           // Failed attempts per provider subject or refresh token family
+	LockoutWindow           time.Duration // Failures are forgotten this long after the last one
+	LockoutDuration         time.Duration // First lockout; doubles with every further failure
+	LockoutMaxDuration      time.Duration
+
+	// CAPTCHA required at sign-in after repeated failures (enabled by CAPTCHA_SECRET)
+	// reCAPTCHA, hCaptcha and Turnstile share the siteverify API
+	CaptchaThreshold int
+	CaptchaVerifyURL string
+	CaptchaSecret    string
+
+	// User cache: Redis, shared by all instances, and an optional in-process tier (size 0 disables it)
+	UserCacheTTL       time.Duration
+	UserCacheLocalSize int
+	UserCacheLocalTTL  time.Duration // Writes on other instances show up after at most this long
 }
 
 // Load reads configuration from environment variables
@@ -45,12 +120,86 @@ func Load() (*Config, error) {
 		DBMinConns:     int32(getEnvAsInt("DB_MIN_CONNS", 5)),
 		JWTSecret:      getEnv("JWT_SECRET", ""),
 		// Redis configuration
-		RedisHost:        getEnv("REDIS_HOST", "localhost"),
-		RedisPort:        getEnv("REDIS_PORT", "6379"),
-		RedisDB:          getEnvAsInt("REDIS_DB", 0),
-		RedisPassword:    getEnv("REDIS_PASSWORD", ""),
-		RedisMaxConns:    getEnvAsInt("REDIS_MAX_CONNS", 10),
+		RedisHost:         getEnv("REDIS_HOST", "localhost"),
+		RedisPort:         getEnv("REDIS_PORT", "6379"),
+		RedisDB:           getEnvAsInt("REDIS_DB", 0),
+		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
+		RedisMaxConns:     getEnvAsInt("REDIS_MAX_CONNS", 10),
 		RedisMinIdleConns: getEnvAsInt("REDIS_MIN_IDLE_CONNS", 2),
+
+		// Apple REST API configuration
+		AppleClientSecret:       getEnv("APPLE_CLIENT_SECRET", ""),
+		AppleKeyID:              getEnv("APPLE_KEY_ID", ""),
+		ApplePrivateKeyPath:     getEnv("APPLE_PRIVATE_KEY_PATH", ""),
+		AppleTokenURL:           getEnv("APPLE_TOKEN_URL", "https://appleid.apple.com/auth/token"),
+		AppleRevokeURL:          getEnv("APPLE_REVOKE_URL", "https://appleid.apple.com/auth/revoke"),
+		AppleRedirectURI:        getEnv("APPLE_REDIRECT_URI", ""),
+		AppleTokenEncryptionKey: getEnv("APPLE_TOKEN_ENCRYPTION_KEY", ""),
+
+		AppleTokenValidationInterval: getEnvAsDuration("APPLE_TOKEN_VALIDATION_INTERVAL", 24*time.Hour),
+		AppleNotificationMaxAge:      getEnvAsDuration("APPLE_NOTIFICATION_MAX_AGE", 72*time.Hour),
+
+		// Google Cross-Account Protection (RISC) configuration
+		GoogleRISCIssuer:  getEnv("GOOGLE_RISC_ISSUER", "https://accounts.google.com/"),
+		GoogleRISCJWKSURL: getEnv("GOOGLE_RISC_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs"),
+		GoogleRISCMaxAge:  getEnvAsDuration("GOOGLE_RISC_MAX_AGE", 72*time.Hour),
+
+		// Generic OpenID Connect providers
+		OIDCProviders: loadOIDCProviders(getEnv("OIDC_PROVIDERS", "")),
+
+		// Account linking policy
+		LinkTrustedEmailDomains: parseEmailDomains(getEnv("LINK_TRUSTED_EMAIL_DOMAINS", "")),
+
+		// Asymmetric JWT signing
+		JWTSigningKeyPath: getEnv("JWT_SIGNING_KEY_PATH", ""),
+		JWTSigningKeyID:   getEnv("JWT_SIGNING_KEY_ID", ""),
+
+		// Signing key rotation
+		JWTKeyEncryptionKey:    getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+		JWTKeyAlgorithm:        getEnv("JWT_KEY_ALGORITHM", "ES256"),
+		JWTKeyRotationInterval: getEnvAsDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		JWTKeyPublishDelay:     getEnvAsDuration("JWT_KEY_PUBLISH_DELAY", 90*time.Minute),
+
+		// Token claims and lifetimes
+		JWTIssuer:          getEnv("JWT_ISSUER", "apple-oauth-backend"),
+		JWTAudience:        getEnv("JWT_AUDIENCE", "apple-oauth-client"),
+		JWTAccessTokenTTL:  getEnvAsDuration("JWT_ACCESS_TOKEN_TTL", 24*time.Hour),
+		JWTRefreshTokenTTL: getEnvAsDuration("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		JWTClients:         loadTokenClients(getEnv("JWT_CLIENTS", "")),
+
+		// Rate limiting
+		RateLimitPolicies: loadRateLimitPolicies(),
+
+		// Brute-force protection
+		LockoutIPThreshold:      getEnvAsInt("LOCKOUT_IP_THRESHOLD", 50),
+		LockoutSubjectThreshold: getEnvAsInt("LOCKOUT_SUBJECT_THRESHOLD", 10),
+		LockoutWindow:           getEnvAsDuration("LOCKOUT_WINDOW", 15*time.Minute),
+		LockoutDuration:         getEnvAsDuration("LOCKOUT_DURATION", time.Minute),
+		LockoutMaxDuration:      getEnvAsDuration("LOCKOUT_MAX_DURATION", time.Hour),
+		CaptchaThreshold:        getEnvAsInt("CAPTCHA_THRESHOLD", 3),
+		CaptchaVerifyURL:        getEnv("CAPTCHA_VERIFY_URL", ""),
+		CaptchaSecret:           getEnv("CAPTCHA_SECRET", ""),
+
+		// User cache
+		UserCacheTTL:       getEnvAsDuration("USER_CACHE_TTL", 15*time.Minute),
+		UserCacheLocalSize: getEnvAsInt("USER_CACHE_LOCAL_SIZE", 10000),
+		UserCacheLocalTTL:  getEnvAsDuration("USER_CACHE_LOCAL_TTL", 5*time.Second),
+	}
+
+	if until := getEnv("JWT_LEGACY_CLAIMS_UNTIL", ""); until != "" {
+		legacyClaimsUntil, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_LEGACY_CLAIMS_UNTIL must be an RFC 3339 time: %w", err)
+		}
+		cfg.JWTLegacyClaimsUntil = legacyClaimsUntil
+	}
+
+	if until := getEnv("JWT_HMAC_ACCEPTED_UNTIL", ""); until != "" {
+		hmacAcceptedUntil, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_HMAC_ACCEPTED_UNTIL must be an RFC 3339 time: %w", err)
+		}
+		cfg.JWTHMACAcceptedUntil = hmacAcceptedUntil
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -63,16 +212,17 @@ func Load() (*Config, error) {
 // validate ensures required configuration is present
 func (c *Config) validate() error {
 	// At least one OAuth provider must be configured
-	if c.AppleClientID == "" && c.GoogleClientID == "" {
-		return fmt.Errorf("at least one OAuth provider (APPLE_CLIENT_ID or GOOGLE_CLIENT_ID) is required")
+	if c.AppleClientID == "" && c.GoogleClientID == "" && len(c.OIDCProviders) == 0 {
+		return fmt.Errorf("at least one OAuth provider (APPLE_CLIENT_ID, GOOGLE_CLIENT_ID or OIDC_PROVIDERS) is required")
 	}
 	if c.DatabaseURL == "" {
 		return fmt.Errorf("DATABASE_URL is required")
 	}
-	if c.JWTSecret == "" {
-		return fmt.Errorf("JWT_SECRET is required")
+	// JWT_SECRET signs tokens unless an asymmetric key is configured
+	if c.JWTSecret == "" && c.JWTSigningKeyPath == "" && c.JWTKeyEncryptionKey == "" {
+		return fmt.Errorf("JWT_SECRET, JWT_SIGNING_KEY_PATH or JWT_KEY_ENCRYPTION_KEY is required")
 	}
-	if len(c.JWTSecret) < 32 {
+	if c.JWTSecret != "" && len(c.JWTSecret) < 32 {
 		return fmt.Errorf("JWT_SECRET must be at least 32 characters long for security")
 	}
 
@@ -109,9 +259,95 @@ func (c *Config) validate() error {
 		return fmt.Errorf("REDIS_MIN_IDLE_CONNS (%d) cannot exceed REDIS_MAX_CONNS (%d)", c.RedisMinIdleConns, c.RedisMaxConns)
 	}
 
+	// Apple client secret generation needs the full key configuration
+	if c.ApplePrivateKeyPath != "" || c.AppleKeyID != "" {
+		if c.AppleTeamID == "" || c.AppleKeyID == "" || c.ApplePrivateKeyPath == "" || c.AppleClientID == "" {
+			return fmt.Errorf("APPLE_TEAM_ID, APPLE_KEY_ID, APPLE_PRIVATE_KEY_PATH and APPLE_CLIENT_ID are required to sign Apple client secrets")
+		}
+	}
+	if c.AppleTokenEncryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.AppleTokenEncryptionKey)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("APPLE_TOKEN_ENCRYPTION_KEY must be a base64-encoded 32-byte key")
+		}
+	}
+	// Apple throttles refresh token validation to once a day per token
+	if c.AppleTokenValidationInterval != 0 && c.AppleTokenValidationInterval < 24*time.Hour {
+		return fmt.Errorf("APPLE_TOKEN_VALIDATION_INTERVAL must be 0 or at least 24h")
+	}
+	if c.AppleNotificationMaxAge < time.Hour {
+		return fmt.Errorf("APPLE_NOTIFICATION_MAX_AGE must be at least 1h")
+	}
+	if c.GoogleRISCMaxAge < time.Hour {
+		return fmt.Errorf("GOOGLE_RISC_MAX_AGE must be at least 1h")
+	}
+
+	if err := validateOIDCProviders(c.OIDCProviders); err != nil {
+		return err
+	}
+
+	// Signing key rotation
+	if c.JWTKeyEncryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.JWTKeyEncryptionKey)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must be a base64-encoded 32-byte key")
+		}
+		switch c.JWTKeyAlgorithm {
+		case "RS256", "ES256", "EdDSA":
+		default:
+			return fmt.Errorf("JWT_KEY_ALGORITHM must be RS256, ES256 or EdDSA, got %q", c.JWTKeyAlgorithm)
+		}
+		if c.JWTKeyPublishDelay < 0 {
+			return fmt.Errorf("JWT_KEY_PUBLISH_DELAY cannot be negative")
+		}
+		// Each new key must activate before the next rotation
+		if c.JWTKeyRotationInterval != 0 && c.JWTKeyRotationInterval <= c.JWTKeyPublishDelay {
+			return fmt.Errorf("JWT_KEY_ROTATION_INTERVAL (%s) must exceed JWT_KEY_PUBLISH_DELAY (%s)", c.JWTKeyRotationInterval, c.JWTKeyPublishDelay)
+		}
+	}
+
+	// Token claims and lifetimes
+	if c.JWTIssuer == "" || c.JWTAudience == "" {
+		return fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE cannot be empty")
+	}
+	if err := validateTokenLifetimes(c.JWTAccessTokenTTL, c.JWTRefreshTokenTTL, c.JWTClients); err != nil {
+		return err
+	}
+
+	if err := validateRateLimitPolicies(c.RateLimitPolicies); err != nil {
+		return err
+	}
+
+	// Brute-force protection
+	if c.LockoutIPThreshold < 0 || c.LockoutSubjectThreshold < 0 || c.CaptchaThreshold < 0 {
+		return fmt.Errorf("LOCKOUT_IP_THRESHOLD, LOCKOUT_SUBJECT_THRESHOLD and CAPTCHA_THRESHOLD cannot be negative")
+	}
+	if c.LockoutWindow <= 0 || c.LockoutDuration <= 0 {
+		return fmt.Errorf("LOCKOUT_WINDOW and LOCKOUT_DURATION must be positive")
+	}
+	if c.LockoutMaxDuration < c.LockoutDuration {
+		return fmt.Errorf("LOCKOUT_MAX_DURATION (%s) cannot be shorter than LOCKOUT_DURATION (%s)", c.LockoutMaxDuration, c.LockoutDuration)
+	}
+	if c.CaptchaSecret != "" && !strings.HasPrefix(c.CaptchaVerifyURL, "https://") {
+		return fmt.Errorf("CAPTCHA_VERIFY_URL must be an https URL when CAPTCHA_SECRET is set")
+	}
+
+	// User cache
+	if c.UserCacheTTL <= 0 || c.UserCacheLocalTTL <= 0 {
+		return fmt.Errorf("USER_CACHE_TTL and USER_CACHE_LOCAL_TTL must be positive")
+	}
+	if c.UserCacheLocalSize < 0 {
+		return fmt.Errorf("USER_CACHE_LOCAL_SIZE cannot be negative")
+	}
+
 	return nil
 }
 
+// HasAppleClientSecret reports whether Apple's REST API can be called
+func (c *Config) HasAppleClientSecret() bool {
+	return c.AppleClientID != "" && (c.ApplePrivateKeyPath != "" || c.AppleClientSecret != "")
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -135,6 +371,21 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+// getEnvAsDuration retrieves an environment variable as a duration (e.g. "90m", "720h")
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
 // parseAllowedOrigins parses comma-separated origins
 func parseAllowedOrigins(origins string) []string {
 	if origins == "" {
@@ -151,3 +402,15 @@ func parseAllowedOrigins(origins string) []string {
 	}
 	return result
 }
+
+// parseEmailDomains parses comma-separated email domains, lowercased
+func parseEmailDomains(domains string) []string {
+	result := []string{}
+	for _, domain := range strings.Split(domains, ",") {
+		trimmed := strings.ToLower(strings.TrimSpace(domain))
+		if trimmed != "" {
+			result = append(result, strings.TrimPrefix(trimmed, "@"))
+		}
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Hamid207/ai-code-test1/pkg/validator"
+)
+
+// reservedProviderNames cannot be used for generic providers because they
+// have dedicated handlers or collide with other /api/v1/auth routes
+var reservedProviderNames = map[string]bool{
+	"apple":      true,
+	"google":     true,
+	"refresh":    true,
+	"logout":     true,
+	"logout-all": true,
+}
+
+// OIDCProviderConfig holds the configuration of a generic OpenID Connect provider
+// Each provider listed in OIDC_PROVIDERS is configured with OIDC_<NAME>_ISSUER and OIDC_<NAME>_CLIENT_ID,
+// where <NAME> is the upper-cased provider name with dashes replaced by underscores
+type OIDCProviderConfig struct {
+	Name     string // Used in the sign-in route: POST /api/v1/auth/{name}
+	Issuer   string // Discovery is performed against {issuer}/.well-known/openid-configuration
+	ClientID string // Expected ID token audience
+}
+
+// loadOIDCProviders reads the configuration of each provider in a comma-separated name list
+func loadOIDCProviders(names string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:     name,
+			Issuer:   getEnv(prefix+"ISSUER", ""),
+			ClientID: getEnv(prefix+"CLIENT_ID", ""),
+		})
+	}
+	return providers
+}
+
+// validateOIDCProviders ensures every generic provider is fully and safely configured
+func validateOIDCProviders(providers []OIDCProviderConfig) error {
+	seen := make(map[string]bool)
+	for _, provider := range providers {
+		// Names must pass the same check as provider names in requests
+		if validator.ValidateProvider(provider.Name) != nil {
+			return fmt.Errorf("OIDC provider name %q must be 2-32 lowercase letters, digits or dashes", provider.Name)
+		}
+		if reservedProviderNames[provider.Name] {
+			return fmt.Errorf("OIDC provider name %q is reserved", provider.Name)
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("OIDC provider %q is listed more than once", provider.Name)
+		}
+		seen[provider.Name] = true
+
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("OIDC provider %q requires an issuer and a client ID", provider.Name)
+		}
+		if err := validateIssuerURL(provider.Issuer); err != nil {
+			return fmt.Errorf("OIDC provider %q: %w", provider.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateIssuerURL requires HTTPS issuers, allowing plain HTTP only for local test providers
+func validateIssuerURL(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid issuer URL %q", issuer)
+	}
+
+	if u.Scheme == "https" {
+		return nil
+	}
+	if u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1") {
+		return nil
+	}
+	return fmt.Errorf("issuer URL %q must use https", issuer)
+}
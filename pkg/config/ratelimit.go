@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rate limit policies used by the API routes
+const (
+	RateLimitSignIn  = "signin"  // Sign-in endpoints, per IP
+	RateLimitRefresh = "refresh" // Token refresh, per IP
+	RateLimitAPI     = "api"     // Authenticated endpoints, per user
+)
+
+// Rate limit keys: what a policy counts requests by
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyUser = "user"
+)
+
+// rateLimitAlgorithms lists the algorithms a policy can use (see model.RateLimitAlgorithm)
+var rateLimitAlgorithms = []string{"fixed_window", "sliding_window", "gcra"}
+
+// RateLimitPolicyConfig limits the requests each client can send to the routes using a policy
+// Each policy is configured with RATE_LIMIT_<NAME>_LIMIT, RATE_LIMIT_<NAME>_PERIOD, RATE_LIMIT_<NAME>_KEY
+// and RATE_LIMIT_<NAME>_ALGORITHM, where <NAME> is the upper-cased policy name. A limit of 0 disables the policy.
+type RateLimitPolicyConfig struct {
+	Name      string
+	Limit     int
+	Period    time.Duration
+	Key       string // RateLimitKeyIP or RateLimitKeyUser
+	Algorithm string // fixed_window, sliding_window or gcra
+}
+
+// defaultRateLimitPolicies lists every policy with its default limit
+// Sign-in gets an exact sliding window; refresh and API traffic may burst, then is spaced evenly
+var defaultRateLimitPolicies = []RateLimitPolicyConfig{
+	{Name: RateLimitSignIn, Limit: 10, Period: time.Minute, Key: RateLimitKeyIP, Algorithm: "sliding_window"},
+	{Name: RateLimitRefresh, Limit: 30, Period: time.Minute, Key: RateLimitKeyIP, Algorithm: "gcra"},
+	{Name: RateLimitAPI, Limit: 120, Period: time.Minute, Key: RateLimitKeyUser, Algorithm: "gcra"},
+}
+
+// loadRateLimitPolicies reads the configuration of each policy, falling back to its defaults
+func loadRateLimitPolicies() []RateLimitPolicyConfig {
+	policies := make([]RateLimitPolicyConfig, 0, len(defaultRateLimitPolicies))
+	for _, policy := range defaultRateLimitPolicies {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(policy.Name) + "_"
+		policies = append(policies, RateLimitPolicyConfig{
+			Name:      policy.Name,
+			Limit:     getEnvAsInt(prefix+"LIMIT", policy.Limit),
+			Period:    getEnvAsDuration(prefix+"PERIOD", policy.Period),
+			Key:       strings.ToLower(getEnv(prefix+"KEY", policy.Key)),
+			Algorithm: strings.ToLower(getEnv(prefix+"ALGORITHM", policy.Algorithm)),
+		})
+	}
+	return policies
+}
+
+// validateRateLimitPolicies ensures every rate limit policy is usable
+func validateRateLimitPolicies(policies []RateLimitPolicyConfig) error {
+	for _, policy := range policies {
+		name := "RATE_LIMIT_" + strings.ToUpper(policy.Name)
+		if policy.Limit < 0 {
+			return fmt.Errorf("%s_LIMIT cannot be negative", name)
+		}
+		// Rate limits are tracked in milliseconds
+		if policy.Period < time.Millisecond || policy.Period%time.Millisecond != 0 {
+			return fmt.Errorf("%s_PERIOD must be a whole number of milliseconds, got %s", name, policy.Period)
+		}
+		if policy.Key != RateLimitKeyIP && policy.Key != RateLimitKeyUser {
+			return fmt.Errorf("%s_KEY must be %q or %q, got %q", name, RateLimitKeyIP, RateLimitKeyUser, policy.Key)
+		}
+		if !slices.Contains(rateLimitAlgorithms, policy.Algorithm) {
+			return fmt.Errorf("%s_ALGORITHM must be one of %s, got %q", name, strings.Join(rateLimitAlgorithms, ", "), policy.Algorithm)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package google
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://accounts.google.com/"
+	testAudience = "123.apps.googleusercontent.com"
+	testKeyID    = "test-key"
+)
+
+// newTestKeys serves the public half of a new RSA key as a JWKS and returns the key and the JWKS URL
+func newTestKeys(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	t.Cleanup(server.Close)
+	return key, server.URL
+}
+
+// signSecurityEvent signs security event token claims as Google does
+func signSecurityEvent(t *testing.T, key *rsa.PrivateKey, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// securityEventClaims returns the claims of a sessions-revoked token issued at issuedAt
+func securityEventClaims(issuedAt time.Time) *SecurityEventClaims {
+	return &SecurityEventClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   testIssuer,
+			Audience: jwt.ClaimStrings{testAudience},
+			ID:       "event-1",
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+		Events: map[string]SecurityEvent{
+			EventSessionsRevoked: {Subject: SecurityEventSubject{SubjectType: "iss-sub", Issuer: testIssuer, Subject: "1234567890"}},
+		},
+	}
+}
+
+func TestVerifySecurityEvent(t *testing.T) {
+	key, jwksURL := newTestKeys(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		maxAge    time.Duration // Default window if zero
+		claims    func(claims *SecurityEventClaims)
+		signer    *rsa.PrivateKey
+		wantStale bool
+		wantErr   bool
+	}{
+		{name: "fresh"},
+		{
+			name: "near the end of the window",
+			claims: func(c *SecurityEventClaims) {
+				c.IssuedAt = jwt.NewNumericDate(now.Add(-DefaultSecurityEventMaxAge + time.Minute))
+			},
+		},
+		{
+			name: "older than the window",
+			claims: func(c *SecurityEventClaims) {
+				c.IssuedAt = jwt.NewNumericDate(now.Add(-DefaultSecurityEventMaxAge - time.Minute))
+			},
+			wantStale: true,
+		},
+		{
+			name:      "older than a configured window",
+			maxAge:    time.Hour,
+			claims:    func(c *SecurityEventClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour)) },
+			wantStale: true,
+		},
+		{
+			name:   "issued in the future within the clock skew",
+			claims: func(c *SecurityEventClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(30 * time.Second)) },
+		},
+		{
+			name:      "issued further in the future",
+			claims:    func(c *SecurityEventClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour)) },
+			wantStale: true,
+		},
+		{
+			name:    "missing iat",
+			claims:  func(c *SecurityEventClaims) { c.IssuedAt = nil },
+			wantErr: true,
+		},
+		{
+			name:    "missing jti",
+			claims:  func(c *SecurityEventClaims) { c.ID = "" },
+			wantErr: true,
+		},
+		{
+			name:    "no events",
+			claims:  func(c *SecurityEventClaims) { c.Events = nil },
+			wantErr: true,
+		},
+		{
+			name:    "addressed to another client",
+			claims:  func(c *SecurityEventClaims) { c.Audience = jwt.ClaimStrings{"456.apps.googleusercontent.com"} },
+			wantErr: true,
+		},
+		{
+			name:    "another issuer",
+			claims:  func(c *SecurityEventClaims) { c.Issuer = "https://example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "signed with another key",
+			signer:  otherKey,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewRISCVerifier(testIssuer, jwksURL, testAudience)
+			if tt.maxAge > 0 {
+				verifier.WithMaxAge(tt.maxAge)
+			}
+			claims := securityEventClaims(now)
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			signer := key
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+
+			verified, err := verifier.VerifySecurityEvent(signSecurityEvent(t, signer, claims))
+			if got := errors.Is(err, ErrSecurityEventStale); got != tt.wantStale {
+				t.Fatalf("err = %v, want stale %t", err, tt.wantStale)
+			}
+			if tt.wantStale {
+				return
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", verified)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified.ID != claims.ID || verified.Events[EventSessionsRevoked].Subject.Subject != "1234567890" {
+				t.Errorf("claims = %+v", verified)
+			}
+		})
+	}
+}
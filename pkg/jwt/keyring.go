@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RingKey is a signing key with its lifetime in a key ring
+type RingKey struct {
+	Key *SigningKey
+
+	// ActivatesAt is when the key starts signing new tokens; until then it only verifies,
+	// so every instance and JWKS consumer knows it before the first token it signs
+	ActivatesAt time.Time
+
+	// ExpiresAt is when the key stops verifying tokens (zero while it is the newest key)
+	ExpiresAt time.Time
+}
+
+// usable reports whether the key verifies tokens at now
+func (k RingKey) usable(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// KeyRing holds the keys that sign and verify tokens, selected by kid
+// The active signing key is the most recently activated one; older keys only verify
+// tokens they signed until they expire
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys map[string]RingKey
+}
+
+// NewKeyRing creates an empty key ring
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		keys: make(map[string]RingKey),
+	}
+}
+
+// Add adds a key to the ring, replacing a key with the same kid
+func (r *KeyRing) Add(key RingKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[key.Key.ID] = key
+}
+
+// Replace atomically replaces every key in the ring
+func (r *KeyRing) Replace(keys []RingKey) {
+	replacement := make(map[string]RingKey, len(keys))
+	for _, key := range keys {
+		replacement[key.Key.ID] = key
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = replacement
+}
+
+// SigningKey returns the key that signs new tokens at now, or nil if no key is active
+func (r *KeyRing) SigningKey(now time.Time) *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var active *RingKey
+	for _, key := range r.keys {
+		if key.ActivatesAt.After(now) || !key.usable(now) {
+			continue
+		}
+		if active == nil || key.ActivatesAt.After(active.ActivatesAt) {
+			active = &key
+		}
+	}
+
+	if active == nil {
+		return nil
+	}
+	return active.Key
+}
+
+// VerificationKey returns the key with the given kid if it verifies tokens at now
+func (r *KeyRing) VerificationKey(kid string, now time.Time) *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	if !ok || !key.usable(now) {
+		return nil
+	}
+	return key.Key
+}
+
+// Keys returns the keys that verify tokens at now, including keys not yet active, oldest first
+func (r *KeyRing) Keys(now time.Time) []RingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]RingKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.usable(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+	return keys
+}
@@ -1,7 +1,11 @@
 package jwt
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,13 +20,132 @@ const (
 	RefreshToken TokenType = "refresh"
 )
 
+// Defaults applied to zero Config fields
+const (
+	DefaultIssuer          = "apple-oauth-backend"
+	DefaultAudience        = "apple-oauth-client"
+	DefaultAccessTokenTTL  = 24 * time.Hour
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// Config holds configuration for a token service
+type Config struct {
+	SecretKey       string // HS256 secret; may be empty when an asymmetric signing key is set
+	Issuer          string // iss of issued tokens, required when validating
+	Audience        string // aud of issued tokens, required when validating
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Clients overrides token lifetimes per client ID (X-Client-ID); zero fields use the defaults
+	// Overrides apply only to clients that authenticate with their secret (see AuthenticateClient)
+	Clients map[string]ClientConfig
+
+	// LegacyClaimsUntil ends the migration window in which tokens with the version 1 claims
+	// schema (user_id/apple_id) are still accepted; zero accepts them indefinitely
+	LegacyClaimsUntil time.Time
+
+	// HMACAcceptedUntil ends the window in which HS256 tokens signed with SecretKey are still
+	// verified once the key ring has an active key; zero refuses them as soon as one is active
+	HMACAcceptedUntil time.Time
+}
+
+// ClientConfig overrides token lifetimes for one client
+type ClientConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Secret          string // Proves the client ID; clients without one are never selected
+}
+
+// ClaimsVersion is the version of the claims schema of issued tokens (ver claim)
+// Version 1 tokens carry no ver claim, the user ID in user_id and a provider subject in apple_id;
+// version 2 tokens carry the user ID in sub and the identity provider in idp.
+// While version 1 tokens are accepted, version 2 tokens also carry user_id and apple_id for
+// consumers that have not moved to sub yet.
+const ClaimsVersion = 2
+
+// Subject describes the user a token pair is issued to
+type Subject struct {
+	UserID   int64
+	Email    string
+	Provider string   // Identity provider the user signed in with (idp claim)
+	Roles    []string // Optional; only carried by access tokens
+	Scopes   []string // Optional; only carried by access tokens
+
+	// Provider subject, emitted as apple_id while version 1 claims are accepted
+	LegacySubject string
+}
+
 // TokenClaims represents JWT token claims
+// ValidateToken normalizes both claims schemas: UserID is always set, Provider is empty for version 1
 type TokenClaims struct {
-	UserID    int64     `json:"user_id"`
-	AppleID   string    `json:"apple_id"`
+	UserID    int64     `json:"-"` // sub, as a number
+	Provider  string    `json:"idp,omitempty"`
 	Email     string    `json:"email"`
+	Roles     []string  `json:"roles,omitempty"`
+	Scope     string    `json:"scope,omitempty"` // Space-separated scopes
 	TokenType TokenType `json:"token_type"`
+	FamilyID  string    `json:"fid,omitempty"` // Refresh token family (one per sign-in)
+	ClientID  string    `json:"cid,omitempty"` // Client the tokens were issued to, if configured
+	Version   int       `json:"ver,omitempty"`
+
+	// iat in milliseconds, so revocations tell apart tokens issued within the same second
+	IssuedAtMillis int64 `json:"iat_ms,omitempty"`
+
 	jwt.RegisteredClaims
+
+	// Version 1 claims, read from version 1 tokens and emitted during the migration window
+	LegacyUserID  int64  `json:"user_id,omitempty"`
+	LegacyAppleID string `json:"apple_id,omitempty"`
+}
+
+// Scopes returns the token's scopes
+func (c *TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the token carries a scope
+func (c *TokenClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the token carries a role
+func (c *TokenClaims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueTime returns when the token was issued, in milliseconds if the token carries iat_ms
+// Tokens without it report the start of the second they were issued in
+func (c *TokenClaims) IssueTime() time.Time {
+	if c.IssuedAtMillis != 0 {
+		return time.UnixMilli(c.IssuedAtMillis)
+	}
+	if c.IssuedAt == nil {
+		return time.Time{}
+	}
+	return c.IssuedAt.Time
+}
+
+// IssuedTo returns the user the token was issued to, for issuing a new token pair
+func (c *TokenClaims) IssuedTo() Subject {
+	return Subject{
+		UserID:   c.UserID,
+		Email:    c.Email,
+		Provider: c.Provider,
+		Roles:    c.Roles,
+		Scopes:   c.Scopes(),
+
+		LegacySubject: c.LegacyAppleID,
+	}
 }
 
 // TokenPair holds access and refresh tokens
@@ -31,30 +154,121 @@ type TokenPair struct {
 	RefreshToken          string    `json:"refresh_token"`
 	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
 	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	RefreshTokenID        string    `json:"-"` // jti of the refresh token, stored for tracking
 }
 
 // TokenService handles JWT token operations
+// Tokens are signed with the key ring's active key and a kid header; while the ring has no
+// active key they are signed with HS256 using the secret key. After the switch to asymmetric
+// keys the secret key only verifies HS256 tokens until Config.HMACAcceptedUntil.
 type TokenService struct {
 	secretKey []byte
+	ring      *KeyRing
+	issuer    string
+	audience  string
+	lifetimes ClientConfig
+	clients   map[string]ClientConfig
+	parser    *jwt.Parser
+
+	legacyClaimsUntil time.Time
+	hmacAcceptedUntil time.Time
 }
 
 // NewTokenService creates a new token service
-func NewTokenService(secretKey string) *TokenService {
+func NewTokenService(cfg Config) *TokenService {
+	lifetimes := ClientConfig{
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+	}
+	if lifetimes.AccessTokenTTL <= 0 {
+		lifetimes.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if lifetimes.RefreshTokenTTL <= 0 {
+		lifetimes.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+
+	// Resolve client overrides once so every lookup returns complete lifetimes
+	clients := make(map[string]ClientConfig, len(cfg.Clients))
+	for clientID, client := range cfg.Clients {
+		if client.AccessTokenTTL <= 0 {
+			client.AccessTokenTTL = lifetimes.AccessTokenTTL
+		}
+		if client.RefreshTokenTTL <= 0 {
+			client.RefreshTokenTTL = lifetimes.RefreshTokenTTL
+		}
+		clients[clientID] = client
+	}
+
+	issuer := cfg.Issuer
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+	audience := cfg.Audience
+	if audience == "" {
+		audience = DefaultAudience
+	}
+
 	return &TokenService{
-		secretKey: []byte(secretKey),
+		secretKey: []byte(cfg.SecretKey),
+		ring:      NewKeyRing(),
+		issuer:    issuer,
+		audience:  audience,
+		lifetimes: lifetimes,
+		clients:   clients,
+		// Tokens minted by another deployment sharing a key must not be accepted here
+		parser: jwt.NewParser(
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithExpirationRequired(),
+		),
+		legacyClaimsUntil: cfg.LegacyClaimsUntil,
+		hmacAcceptedUntil: cfg.HMACAcceptedUntil,
+	}
+}
+
+// WithSigningKey signs new tokens with a single asymmetric key that never expires
+func (s *TokenService) WithSigningKey(key *SigningKey) *TokenService {
+	s.ring.Add(RingKey{Key: key})
+	return s
+}
+
+// WithKeyRing signs and verifies tokens with the keys of a key ring
+// The ring may be updated while the service is in use, e.g. by key rotation
+func (s *TokenService) WithKeyRing(ring *KeyRing) *TokenService {
+	s.ring = ring
+	return s
+}
+
+// JWKS returns the public keys that verify tokens issued by this service
+// Keys are published from the moment they are added to the ring until they expire
+func (s *TokenService) JWKS() JSONWebKeySet {
+	keys := s.ring.Keys(time.Now())
+
+	keySet := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(keys))}
+	for _, key := range keys {
+		keySet.Keys = append(keySet.Keys, key.Key.PublicJWK())
 	}
+	return keySet
+}
+
+// NewFamilyID generates a new refresh token family ID
+// A family starts at sign-in and is carried forward by every rotation
+func NewFamilyID() string {
+	return uuid.New().String()
 }
 
 // GenerateTokenPair generates both access and refresh tokens
-func (s *TokenService) GenerateTokenPair(userID int64, appleID, email string) (*TokenPair, error) {
-	// Generate access token (24 hours)
-	accessToken, accessExpiresAt, err := s.generateToken(userID, appleID, email, AccessToken, 24*time.Hour)
+// Both tokens carry familyID so a whole family can be revoked at once.
+// Lifetimes follow clientID's overrides; unknown client IDs get the defaults.
+func (s *TokenService) GenerateTokenPair(subject Subject, familyID, clientID string) (*TokenPair, error) {
+	clientID, lifetimes := s.clientLifetimes(clientID)
+
+	accessToken, _, accessExpiresAt, err := s.generateToken(subject, familyID, clientID, AccessToken, lifetimes.AccessTokenTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	// Generate refresh token (7 days)
-	refreshToken, refreshExpiresAt, err := s.generateToken(userID, appleID, email, RefreshToken, 7*24*time.Hour)
+	refreshToken, refreshTokenID, refreshExpiresAt, err := s.generateToken(subject, familyID, clientID, RefreshToken, lifetimes.RefreshTokenTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -64,16 +278,59 @@ func (s *TokenService) GenerateTokenPair(userID int64, appleID, email string) (*
 		RefreshToken:          refreshToken,
 		AccessTokenExpiresAt:  accessExpiresAt,
 		RefreshTokenExpiresAt: refreshExpiresAt,
+		RefreshTokenID:        refreshTokenID,
 	}, nil
 }
 
 // GenerateAccessToken generates only an access token
-func (s *TokenService) GenerateAccessToken(userID int64, appleID, email string) (string, time.Time, error) {
-	return s.generateToken(userID, appleID, email, AccessToken, 24*time.Hour)
+func (s *TokenService) GenerateAccessToken(subject Subject, familyID, clientID string) (string, time.Time, error) {
+	clientID, lifetimes := s.clientLifetimes(clientID)
+	token, _, expiresAt, err := s.generateToken(subject, familyID, clientID, AccessToken, lifetimes.AccessTokenTTL)
+	return token, expiresAt, err
+}
+
+// AccessTokenTTL returns the lifetime of the longest-lived access tokens issued to any client
+// Used to size blacklist entries so they outlive every token they cover
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	ttl := s.lifetimes.AccessTokenTTL
+	for _, client := range s.clients {
+		ttl = max(ttl, client.AccessTokenTTL)
+	}
+	return ttl
+}
+
+// MaxTokenTTL returns the lifetime of the longest-lived tokens issued to any client
+// A retired signing key must keep verifying tokens for this long
+func (s *TokenService) MaxTokenTTL() time.Duration {
+	ttl := max(s.lifetimes.AccessTokenTTL, s.lifetimes.RefreshTokenTTL)
+	for _, client := range s.clients {
+		ttl = max(ttl, client.AccessTokenTTL, client.RefreshTokenTTL)
+	}
+	return ttl
+}
+
+// AuthenticateClient returns clientID if it is configured and secret is its secret, "" otherwise
+// Client IDs come from a request header, so only authenticated ones may select token lifetimes
+func (s *TokenService) AuthenticateClient(clientID, secret string) string {
+	client, ok := s.clients[clientID]
+	if !ok || client.Secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(client.Secret)) != 1 {
+		return ""
+	}
+	return clientID
+}
+
+// clientLifetimes returns the token lifetimes of a client
+// Unknown client IDs are replaced by "" so arbitrary header values never end up in tokens
+func (s *TokenService) clientLifetimes(clientID string) (string, ClientConfig) {
+	if client, ok := s.clients[clientID]; ok {
+		return clientID, client
+	}
+	return "", s.lifetimes
 }
 
 // generateToken generates a JWT token with specified expiration
-func (s *TokenService) generateToken(userID int64, appleID, email string, tokenType TokenType, duration time.Duration) (string, time.Time, error) {
+// Returns: signed token, token ID (jti), expiration time, error
+func (s *TokenService) generateToken(subject Subject, familyID, clientID string, tokenType TokenType, duration time.Duration) (string, string, time.Time, error) {
 	now := time.Now()
 	expiresAt := now.Add(duration)
 
@@ -81,38 +338,63 @@ func (s *TokenService) generateToken(userID int64, appleID, email string, tokenT
 	tokenID := uuid.New().String()
 
 	claims := TokenClaims{
-		UserID:    userID,
-		AppleID:   appleID,
-		Email:     email,
+		Provider:  subject.Provider,
+		Email:     subject.Email,
 		TokenType: tokenType,
+		FamilyID:  familyID,
+		ClientID:  clientID,
+		Version:   ClaimsVersion,
+
+		IssuedAtMillis: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(subject.UserID, 10),
 			ID:        tokenID, // JWT ID (jti) - unique identifier
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    "apple-oauth-backend",
-			Audience:  jwt.ClaimStrings{"apple-oauth-client"}, // Added audience claim
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secretKey)
+	if s.legacyClaimsAccepted(now) {
+		claims.LegacyUserID = subject.UserID
+		claims.LegacyAppleID = subject.LegacySubject
+	}
+
+	// Authorization is resolved again on refresh, so refresh tokens never carry stale grants
+	if tokenType == AccessToken {
+		claims.Roles = subject.Roles
+		claims.Scope = strings.Join(subject.Scopes, " ")
+	}
+
+	tokenString, err := s.sign(claims)
 	if err != nil {
-		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, expiresAt, nil
+	return tokenString, tokenID, expiresAt, nil
+}
+
+// sign signs claims with the active key of the key ring, or with the secret key if none is active
+func (s *TokenService) sign(claims TokenClaims) (string, error) {
+	signingKey := s.ring.SigningKey(time.Now())
+	if signingKey == nil {
+		if len(s.secretKey) == 0 {
+			return "", errors.New("no active signing key")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secretKey)
+	}
+
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	return token.SignedString(signingKey.PrivateKey)
 }
 
 // ValidateToken validates and parses a JWT token
+// The token must carry this service's issuer and audience and an expiry
 func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
+	token, err := s.parser.ParseWithClaims(tokenString, &TokenClaims{}, s.keyFunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -123,6 +405,84 @@ func (s *TokenService) ValidateToken(tokenString string) (*TokenClaims, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	if err := s.normalizeClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// normalizeClaims sets UserID from the claims schema the token was issued with
+func (s *TokenService) normalizeClaims(claims *TokenClaims) error {
+	if claims.Version >= ClaimsVersion {
+		userID, err := strconv.ParseInt(claims.RegisteredClaims.Subject, 10, 64)
+		if err != nil || userID <= 0 {
+			return fmt.Errorf("invalid token subject")
+		}
+		claims.UserID = userID
+		return nil
+	}
+
+	// Version 1: apple_id held the subject of whichever provider was used, so the provider is unknown
+	if !s.legacyClaimsAccepted(time.Now()) {
+		return fmt.Errorf("legacy token claims are no longer accepted")
+	}
+	if claims.LegacyUserID <= 0 {
+		return fmt.Errorf("invalid token subject")
+	}
+	claims.UserID = claims.LegacyUserID
+	return nil
+}
+
+// legacyClaimsAccepted reports whether the version 1 claims migration window is open at now
+func (s *TokenService) legacyClaimsAccepted(now time.Time) bool {
+	return s.legacyClaimsUntil.IsZero() || !now.After(s.legacyClaimsUntil)
+}
+
+// hmacAccepted reports whether HS256 tokens signed with the secret key are verified at now
+// The secret key signs while the ring has no active key; once one is active it only verifies
+// tokens issued before the switch, until the configured cutoff
+func (s *TokenService) hmacAccepted(now time.Time) bool {
+	if s.ring.SigningKey(now) == nil {
+		return true
+	}
+	return !s.hmacAcceptedUntil.IsZero() && !now.After(s.hmacAcceptedUntil)
+}
+
+// keyFunc resolves the key verifying a token from its algorithm and kid header
+// The key's own algorithm must match the token's to prevent algorithm confusion
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(s.secretKey) == 0 || !s.hmacAccepted(time.Now()) {
+			return nil, errors.New("HMAC-signed tokens are not accepted")
+		}
+		return s.secretKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key := s.ring.VerificationKey(kid, time.Now())
+	if key == nil {
+		return nil, fmt.Errorf("unknown or retired signing key: %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PrivateKey.Public(), nil
+}
+
+// ValidateAccessToken validates that the token is an access token
+func (s *TokenService) ValidateAccessToken(tokenString string) (*TokenClaims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != AccessToken {
+		return nil, fmt.Errorf("token is not an access token")
+	}
+
 	return claims, nil
 }
 
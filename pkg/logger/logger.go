@@ -33,3 +33,12 @@ func Sync() {
 		_ = Logger.Sync()
 	}
 }
+
+// SecurityEvent logs a security-relevant event (token reuse, forced revocation, etc.)
+// Events share the "security_event" field so they can be routed to alerting
+func SecurityEvent(event string, fields ...zap.Field) {
+	if Logger == nil {
+		return
+	}
+	Logger.Warn("security event", append([]zap.Field{zap.String("security_event", event)}, fields...)...)
+}
@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// discoveryPath is appended to the issuer to locate the provider metadata
+	discoveryPath = "/.well-known/openid-configuration"
+
+	maxResponseBodySize = 1024 * 1024 // 1MB limit for response body
+)
+
+// ProviderMetadata represents the subset of OpenID Provider Metadata used for ID token verification
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Discover fetches the OpenID Provider Metadata of an issuer
+// The metadata must name the same issuer, as required by OpenID Connect Discovery
+func Discover(ctx context.Context, httpClient *http.Client, issuer string) (*ProviderMetadata, error) {
+	discoveryURL := strings.TrimSuffix(issuer, "/") + discoveryPath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provider metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Limit response body size to prevent memory exhaustion attacks
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var metadata ProviderMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provider metadata: %w", err)
+	}
+
+	if metadata.Issuer != issuer {
+		return nil, fmt.Errorf("provider metadata issuer %q does not match %q", metadata.Issuer, issuer)
+	}
+	if metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata has no jwks_uri")
+	}
+
+	return &metadata, nil
+}
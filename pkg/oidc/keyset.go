@@ -0,0 +1,270 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// keyCacheTTL is how long fetched keys are used before the JWKS is fetched again
+	keyCacheTTL = 24 * time.Hour
+
+	// minRefreshInterval limits refetches triggered by unknown key IDs (key rotation)
+	minRefreshInterval = time.Minute
+)
+
+// JSONWebKeySet represents a JWKS document
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey represents a single public key in a JWKS document
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet caches the RSA, EC and Ed25519 public keys published at a JWKS URL
+type KeySet struct {
+	jwksURL    string
+	httpClient *http.Client
+	mu         sync.RWMutex
+	keys       map[string]crypto.PublicKey
+	lastFetch  time.Time
+}
+
+// NewKeySet creates a key set for the given JWKS URL
+func NewKeySet(jwksURL string, httpClient *http.Client) *KeySet {
+	return &KeySet{
+		jwksURL:    jwksURL,
+		httpClient: httpClient,
+		keys:       make(map[string]crypto.PublicKey),
+	}
+}
+
+// KeyFunc returns a jwt.Keyfunc resolving token keys by kid
+// An unknown kid triggers a refetch so rotated keys are picked up without waiting for the cache to expire
+func (k *KeySet) KeyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("kid not found in token header")
+		}
+
+		if err := k.refreshKeysIfNeeded(ctx, kid); err != nil {
+			return nil, fmt.Errorf("failed to fetch public keys: %w", err)
+		}
+
+		k.mu.RLock()
+		publicKey, ok := k.keys[kid]
+		k.mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("public key not found for kid: %s", kid)
+		}
+
+		return publicKey, nil
+	}
+}
+
+// refreshKeysIfNeeded fetches the JWKS if the cache is stale or does not contain kid
+// Uses double-checked locking to prevent multiple concurrent fetches
+func (k *KeySet) refreshKeysIfNeeded(ctx context.Context, kid string) error {
+	k.mu.RLock()
+	needsRefresh := k.needsRefreshLocked(kid)
+	k.mu.RUnlock()
+
+	if !needsRefresh {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Another goroutine may have already fetched keys while we were waiting for the lock
+	if !k.needsRefreshLocked(kid) {
+		return nil
+	}
+
+	return k.fetchKeysLocked(ctx)
+}
+
+// needsRefreshLocked reports whether the JWKS must be fetched to resolve kid
+// IMPORTANT: Caller must hold k.mu (read or write)
+func (k *KeySet) needsRefreshLocked(kid string) bool {
+	if len(k.keys) == 0 || time.Since(k.lastFetch) >= keyCacheTTL {
+		return true
+	}
+	_, known := k.keys[kid]
+	return !known && time.Since(k.lastFetch) >= minRefreshInterval
+}
+
+// fetchKeysLocked retrieves and parses the JWKS
+// IMPORTANT: Caller must hold write lock (k.mu.Lock) before calling this method
+func (k *KeySet) fetchKeysLocked(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Limit response body size to prevent memory exhaustion attacks
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var keySet JSONWebKeySet
+	if err := json.Unmarshal(body, &keySet); err != nil {
+		return fmt.Errorf("failed to unmarshal keys: %w", err)
+	}
+
+	newKeys := make(map[string]crypto.PublicKey)
+	for _, key := range keySet.Keys {
+		// Skip encryption keys and keys without an ID
+		if key.Kid == "" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := ParseJWK(key)
+		if err != nil {
+			// Skip key types this service cannot verify rather than failing the whole set
+			continue
+		}
+		newKeys[key.Kid] = publicKey
+	}
+
+	if len(newKeys) == 0 {
+		return errors.New("no usable signing keys in JWKS")
+	}
+
+	k.keys = newKeys
+	k.lastFetch = time.Now()
+
+	return nil
+}
+
+// ParseJWK converts a JWK into an RSA, ECDSA or Ed25519 public key
+func ParseJWK(key JSONWebKey) (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		return parseRSAKey(key)
+	case "EC":
+		return parseECKey(key)
+	case "OKP":
+		return parseOKPKey(key)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", key.Kty)
+	}
+}
+
+// parseRSAKey converts an RSA JWK to an RSA public key
+func parseRSAKey(key JSONWebKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+
+	eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+
+	var e int
+	for _, b := range eBytes {
+		e = e<<8 + int(b)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: e,
+	}, nil
+}
+
+// parseECKey converts an EC JWK to an ECDSA public key
+func parseECKey(key JSONWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch key.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", key.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+	}
+
+	yBytes, err := base64.RawURLEncoding.DecodeString(key.Y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode y coordinate: %w", err)
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}
+	if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, errors.New("point is not on curve")
+	}
+
+	return publicKey, nil
+}
+
+// parseOKPKey converts an OKP JWK to an Ed25519 public key
+func parseOKPKey(key JSONWebKey) (ed25519.PublicKey, error) {
+	if key.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve: %s", key.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(xBytes) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key size")
+	}
+
+	return ed25519.PublicKey(xBytes), nil
+}
@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// clockSkew is the leeway allowed when validating exp, iat and nbf
+	clockSkew = time.Minute
+)
+
+// supportedAlgorithms are the asymmetric signing algorithms accepted for ID tokens
+// Symmetric (HS*) and "none" algorithms are never accepted
+var supportedAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Bool is a boolean claim that some providers encode as the string "true" or "false"
+type Bool bool
+
+// UnmarshalJSON accepts both JSON booleans and their string forms
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = Bool(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid boolean claim: %s", data)
+	}
+
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return fmt.Errorf("invalid boolean claim: %s", data)
+	}
+
+	*b = Bool(value)
+	return nil
+}
+
+// Claims represents the standard claims of an OpenID Connect ID token
+type Claims struct {
+	jwt.RegisteredClaims
+	Email             string `json:"email"`
+	EmailVerified     Bool   `json:"email_verified"`
+	Nonce             string `json:"nonce"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+	Locale            string `json:"locale"`
+}
+
+// Config holds configuration for an OpenID Connect verifier
+type Config struct {
+	Issuer     string
+	ClientID   string
+	HTTPClient *http.Client // Defaults to a client with a 10 second timeout
+}
+
+// Verifier verifies ID tokens of any OpenID Connect provider
+// Provider metadata is discovered on first use, so providers that are down at startup do not block it
+type Verifier struct {
+	issuer     string
+	clientID   string
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	keys       *KeySet
+	algorithms []string
+}
+
+// NewVerifier creates a new OpenID Connect ID token verifier
+func NewVerifier(cfg Config) *Verifier {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: 10 * time.Second, // Prevent hanging requests
+		}
+	}
+
+	return &Verifier{
+		issuer:     cfg.Issuer,
+		clientID:   cfg.ClientID,
+		httpClient: httpClient,
+	}
+}
+
+// Issuer returns the issuer this verifier accepts tokens from
+func (v *Verifier) Issuer() string {
+	return v.issuer
+}
+
+// VerifyIDToken verifies an ID token and returns its claims
+// If expectedNonce is non-empty the token must carry the same nonce
+func (v *Verifier) VerifyIDToken(ctx context.Context, idToken, expectedNonce string) (*Claims, error) {
+	keys, algorithms, err := v.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(algorithms),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+
+	token, err := parser.ParseWithClaims(idToken, &Claims{}, keys.KeyFunc(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token missing subject")
+	}
+
+	if expectedNonce != "" && claims.Nonce != expectedNonce {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// discover returns the provider's key set and accepted algorithms, fetching metadata on first use
+func (v *Verifier) discover(ctx context.Context) (*KeySet, []string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys != nil {
+		return v.keys, v.algorithms, nil
+	}
+
+	metadata, err := Discover(ctx, v.httpClient, v.issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover provider %s: %w", v.issuer, err)
+	}
+
+	algorithms := allowedAlgorithms(metadata.IDTokenSigningAlgValuesSupported)
+	if len(algorithms) == 0 {
+		return nil, nil, fmt.Errorf("provider %s supports no asymmetric signing algorithm", v.issuer)
+	}
+
+	v.keys = NewKeySet(metadata.JWKSURI, v.httpClient)
+	v.algorithms = algorithms
+
+	return v.keys, v.algorithms, nil
+}
+
+// allowedAlgorithms intersects the provider's advertised algorithms with the supported ones
+// Providers that do not advertise algorithms default to RS256, as in OpenID Connect Discovery
+func allowedAlgorithms(advertised []string) []string {
+	if len(advertised) == 0 {
+		return []string{"RS256"}
+	}
+
+	var allowed []string
+	for _, alg := range advertised {
+		for _, supported := range supportedAlgorithms {
+			if alg == supported {
+				allowed = append(allowed, alg)
+			}
+		}
+	}
+	return allowed
+}
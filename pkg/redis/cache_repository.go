@@ -11,6 +11,9 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// userCacheTombstone replaces a cached user after an invalidation, so a stale write-back cannot add it again
+const userCacheTombstone = "invalidated"
+
 // CacheRepository implements repository.RedisCacheRepository
 type CacheRepository struct {
 	client     *Client
@@ -25,34 +28,39 @@ func NewCacheRepository(client *Client) *CacheRepository {
 	}
 }
 
-// SetUserCache stores user data in cache with TTL
-func (r *CacheRepository) SetUserCache(ctx context.Context, userID int64, user *model.User, ttl time.Duration) error {
+// AddUserCache stores user data in cache with TTL unless the user is cached or was just invalidated (SET NX)
+// Returns false if nothing was stored
+func (r *CacheRepository) AddUserCache(ctx context.Context, userID int64, user *model.User, ttl time.Duration) (bool, error) {
 	key := r.keyBuilder.UserCache(strconv.FormatInt(userID, 10))
 
 	data, err := json.Marshal(user)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user data: %w", err)
+		return false, fmt.Errorf("failed to marshal user data: %w", err)
 	}
 
-	err = r.client.Set(ctx, key, data, ttl).Err()
+	added, err := r.client.SetNX(ctx, key, data, ttl).Result()
 	if err != nil {
-		return fmt.Errorf("failed to cache user data: %w", err)
+		return false, fmt.Errorf("failed to cache user data: %w", err)
 	}
 
-	return nil
+	return added, nil
 }
 
 // GetUserCache retrieves user data from cache
+// Returns nil if the user is not cached or was just invalidated
 func (r *CacheRepository) GetUserCache(ctx context.Context, userID int64) (*model.User, error) {
 	key := r.keyBuilder.UserCache(strconv.FormatInt(userID, 10))
 
 	data, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return nil, fmt.Errorf("user cache not found")
+		return nil, nil // Not cached
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user cache: %w", err)
 	}
+	if data == userCacheTombstone {
+		return nil, nil
+	}
 
 	var user model.User
 	err = json.Unmarshal([]byte(data), &user)
@@ -64,11 +72,15 @@ func (r *CacheRepository) GetUserCache(ctx context.Context, userID int64) (*mode
 }
 
 // InvalidateUserCache removes user data from cache
-func (r *CacheRepository) InvalidateUserCache(ctx context.Context, userID int64) error {
-	key := r.keyBuilder.UserCache(strconv.FormatInt(userID, 10))
-
-	err := r.client.Del(ctx, key).Err()
-	if err != nil {
+// Both the user and profile entries are removed so no stale copy survives. The user entry is
+// replaced by a tombstone for hold, so AddUserCache cannot store a copy read before the change.
+func (r *CacheRepository) InvalidateUserCache(ctx context.Context, userID int64, hold time.Duration) error {
+	id := strconv.FormatInt(userID, 10)
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, r.keyBuilder.UserCache(id), userCacheTombstone, hold)
+	pipe.Del(ctx, r.keyBuilder.ProfileCache(id))
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to invalidate user cache: %w", err)
 	}
 
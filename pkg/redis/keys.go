@@ -6,13 +6,24 @@ import "fmt"
 // Following naming convention: <namespace>:<entity>:<identifier>
 const (
 	// Token-related keys
-	PrefixRefreshToken  = "refresh"      // refresh:<user_id>:<token_id>
-	PrefixBlacklist     = "blacklist"    // blacklist:<token_id>
-	PrefixTokenFamily   = "token_family" // token_family:<family_id>
+	PrefixRefreshToken   = "refresh"        // refresh:<user_id>:<token_id>
+	PrefixBlacklist      = "blacklist"      // blacklist:<token_id>
+	PrefixUserRevocation = "blacklist:user" // blacklist:user:<user_id>
+	PrefixTokenFamily    = "token_family"   // token_family:<family_id>
+
+	// Account linking keys
+	PrefixLinkTicket = "link_ticket" // link_ticket:<ticket_id>
 
 	// Rate limiting keys
-	PrefixRateLimitUser = "ratelimit:user" // ratelimit:user:<user_id>
-	PrefixRateLimitIP   = "ratelimit:ip"   // ratelimit:ip:<ip_address>
+	PrefixRateLimitUser = "ratelimit:user" // ratelimit:user:<policy>:<algorithm>:<user_id>
+	PrefixRateLimitIP   = "ratelimit:ip"   // ratelimit:ip:<policy>:<algorithm>:<ip_address>
+
+	// Brute-force protection keys
+	PrefixAuthFailures = "auth_failures" // auth_failures:<scope>:<id>
+	PrefixAuthLockout  = "auth_lockout"  // auth_lockout:<scope>:<id>
+
+	// Webhook replay protection keys
+	PrefixWebhookEvent = "webhook_event" // webhook_event:<source>:<event_id>
 
 	// Cache keys
 	PrefixUserCache    = "cache:user"    // cache:user:<user_id>
@@ -56,22 +67,53 @@ func (kb *KeyBuilder) BlacklistToken(tokenID string) string {
 	return fmt.Sprintf("%s:%s", PrefixBlacklist, tokenID)
 }
 
+// UserRevocation builds a key for the per-user token revocation timestamp
+// Format: blacklist:user:<user_id>
+func (kb *KeyBuilder) UserRevocation(userID string) string {
+	return fmt.Sprintf("%s:%s", PrefixUserRevocation, userID)
+}
+
 // TokenFamily builds a key for token family tracking
 // Format: token_family:<family_id>
 func (kb *KeyBuilder) TokenFamily(familyID string) string {
 	return fmt.Sprintf("%s:%s", PrefixTokenFamily, familyID)
 }
 
-// RateLimitUser builds a key for user-based rate limiting
-// Format: ratelimit:user:<user_id>
-func (kb *KeyBuilder) RateLimitUser(userID string) string {
-	return fmt.Sprintf("%s:%s", PrefixRateLimitUser, userID)
+// LinkTicket builds a key for pending account linking tickets
+// Format: link_ticket:<ticket_id>
+func (kb *KeyBuilder) LinkTicket(ticketID string) string {
+	return fmt.Sprintf("%s:%s", PrefixLinkTicket, ticketID)
+}
+
+// RateLimitUser builds a key for user-based rate limiting under a policy
+// The algorithm is part of the key because each one stores a different Redis type
+// Format: ratelimit:user:<policy>:<algorithm>:<user_id>
+func (kb *KeyBuilder) RateLimitUser(policy, algorithm, userID string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", PrefixRateLimitUser, policy, algorithm, userID)
+}
+
+// RateLimitIP builds a key for IP-based rate limiting under a policy
+// Format: ratelimit:ip:<policy>:<algorithm>:<ip_address>
+func (kb *KeyBuilder) RateLimitIP(policy, algorithm, ipAddress string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", PrefixRateLimitIP, policy, algorithm, ipAddress)
+}
+
+// AuthFailures builds a key for counting failed authentication attempts
+// Format: auth_failures:<scope>:<id> (e.g. auth_failures:ip:192.168.1.100)
+func (kb *KeyBuilder) AuthFailures(key string) string {
+	return fmt.Sprintf("%s:%s", PrefixAuthFailures, key)
+}
+
+// AuthLockout builds a key marking a temporary lockout after failed authentication attempts
+// Format: auth_lockout:<scope>:<id>
+func (kb *KeyBuilder) AuthLockout(key string) string {
+	return fmt.Sprintf("%s:%s", PrefixAuthLockout, key)
 }
 
-// RateLimitIP builds a key for IP-based rate limiting
-// Format: ratelimit:ip:<ip_address>
-func (kb *KeyBuilder) RateLimitIP(ipAddress string) string {
-	return fmt.Sprintf("%s:%s", PrefixRateLimitIP, ipAddress)
+// WebhookEvent builds a key marking a webhook event as processed
+// Format: webhook_event:<source>:<event_id>
+func (kb *KeyBuilder) WebhookEvent(source, eventID string) string {
+	return fmt.Sprintf("%s:%s:%s", PrefixWebhookEvent, source, eventID)
 }
 
 // UserCache builds a key for caching user data
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// LinkTicketRepository implements repository.RedisLinkTicketRepository
+type LinkTicketRepository struct {
+	client     *Client
+	keyBuilder *KeyBuilder
+	logger     Logger
+}
+
+// NewLinkTicketRepository creates a new LinkTicketRepository
+func NewLinkTicketRepository(client *Client) *LinkTicketRepository {
+	return &LinkTicketRepository{
+		client:     client,
+		keyBuilder: NewKeyBuilder(),
+		logger:     defaultLogger,
+	}
+}
+
+// WithLogger sets a custom logger for this repository
+func (r *LinkTicketRepository) WithLogger(logger Logger) *LinkTicketRepository {
+	r.logger = logger
+	return r
+}
+
+// StoreLinkTicket stores a pending linking ticket with TTL
+func (r *LinkTicketRepository) StoreLinkTicket(ctx context.Context, ticketID string, ticket *model.LinkTicket, ttl time.Duration) error {
+	key := r.keyBuilder.LinkTicket(ticketID)
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return fmt.Errorf("failed to marshal link ticket: %w", err)
+	}
+
+	err = r.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		r.logger.Error("failed to store link ticket",
+			zap.Int64("user_id", ticket.UserID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to store link ticket: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeLinkTicket atomically retrieves and deletes a linking ticket
+// Returns nil if the ticket does not exist or has expired
+func (r *LinkTicketRepository) ConsumeLinkTicket(ctx context.Context, ticketID string) (*model.LinkTicket, error) {
+	key := r.keyBuilder.LinkTicket(ticketID)
+
+	data, err := r.client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("failed to consume link ticket", zap.Error(err))
+		return nil, fmt.Errorf("failed to consume link ticket: %w", err)
+	}
+
+	var ticket model.LinkTicket
+	if err := json.Unmarshal([]byte(data), &ticket); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal link ticket: %w", err)
+	}
+
+	return &ticket, nil
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutRepository implements repository.RedisLockoutRepository
+type LockoutRepository struct {
+	client     *Client
+	keyBuilder *KeyBuilder
+}
+
+// NewLockoutRepository creates a new LockoutRepository
+func NewLockoutRepository(client *Client) *LockoutRepository {
+	return &LockoutRepository{
+		client:     client,
+		keyBuilder: NewKeyBuilder(),
+	}
+}
+
+// RecordFailure increments the failure count of a key, which is forgotten window after the last failure
+func (r *LockoutRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	failuresKey := r.keyBuilder.AuthFailures(key)
+
+	// MULTI/EXEC so the count never exists without an expiry
+	pipe := r.client.TxPipeline()
+	failures := pipe.Incr(ctx, failuresKey)
+	pipe.PExpire(ctx, failuresKey, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to record authentication failure: %w", err)
+	}
+
+	return failures.Val(), nil
+}
+
+// GetFailures returns the failure count of a key and how long it stays locked out (0 if it is not)
+func (r *LockoutRepository) GetFailures(ctx context.Context, key string) (int64, time.Duration, error) {
+	// Fetch both in a single round trip
+	pipe := r.client.Pipeline()
+	failures := pipe.Get(ctx, r.keyBuilder.AuthFailures(key))
+	lockout := pipe.PTTL(ctx, r.keyBuilder.AuthLockout(key))
+
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return 0, 0, fmt.Errorf("failed to get authentication failures: %w", err)
+	}
+
+	var count int64
+	if failures.Err() != redis.Nil {
+		count, err = failures.Int64()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse authentication failures: %w", err)
+		}
+	}
+
+	// PTTL is negative for missing keys
+	return count, max(lockout.Val(), 0), nil
+}
+
+// Lock locks a key out for duration, keeping its failure count until window after the lockout ends
+// so the next lockout can be longer
+func (r *LockoutRepository) Lock(ctx context.Context, key string, duration, window time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, r.keyBuilder.AuthLockout(key), "1", duration)
+	pipe.PExpire(ctx, r.keyBuilder.AuthFailures(key), duration+window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to lock out: %w", err)
+	}
+
+	return nil
+}
+
+// ResetFailures clears the failure count and lockout of a key
+func (r *LockoutRepository) ResetFailures(ctx context.Context, key string) error {
+	err := r.client.Del(ctx, r.keyBuilder.AuthFailures(key), r.keyBuilder.AuthLockout(key)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to reset authentication failures: %w", err)
+	}
+
+	return nil
+}
@@ -6,20 +6,101 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
-// Lua script for atomic rate limiting (package-level constant for performance)
-// This script atomically increments the counter and returns both count and TTL
-// Reducing this to a constant eliminates per-request string allocation
-const rateLimitScript = `
-	local current = redis.call('INCR', KEYS[1])
-	if current == 1 then
-		redis.call('EXPIRE', KEYS[1], ARGV[1])
-	end
-	local ttl = redis.call('TTL', KEYS[1])
-	return {current, ttl}
-`
+// Lua scripts for atomic rate limiting, one per algorithm (see model.RateLimitAlgorithm)
+// Each returns {allowed, remaining, reset_after_ms, retry_after_ms}
+// Scripts run with EVALSHA, so only their hash is sent once Redis has cached them
+// Times come from the Redis server clock (TIME, in microseconds), so instances with skewed clocks agree
+// and timestamps are formatted with %.0f because Lua would otherwise print them with 14 digits
+var (
+	// fixedWindowScript increments a counter that expires one period after the first request
+	// ARGV: limit, period_ms
+	fixedWindowScript = redis.NewScript(`
+		local limit = tonumber(ARGV[1])
+		local period = tonumber(ARGV[2])
+
+		local current = redis.call('INCR', KEYS[1])
+		if current == 1 then
+			redis.call('PEXPIRE', KEYS[1], period)
+		end
+		local ttl = redis.call('PTTL', KEYS[1])
+		if ttl < 0 then
+			-- The expiry was lost (e.g. the key was persisted); start a new window
+			redis.call('PEXPIRE', KEYS[1], period)
+			ttl = period
+		end
+
+		if current > limit then
+			return {0, 0, ttl, ttl}
+		end
+		return {1, limit - current, ttl, 0}
+	`)
+
+	// slidingWindowScript keeps a sorted set of request times within the last period
+	// Rejected requests are not logged, so a client over the limit regains access as old requests age out
+	// ARGV: limit, period_us, member (unique per request)
+	slidingWindowScript = redis.NewScript(`
+		local limit = tonumber(ARGV[1])
+		local period = tonumber(ARGV[2])
+		local time = redis.call('TIME')
+		local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+		redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', string.format('%.0f', now - period))
+		local count = redis.call('ZCARD', KEYS[1])
+
+		local allowed = 0
+		if count < limit then
+			redis.call('ZADD', KEYS[1], string.format('%.0f', now), ARGV[3])
+			count = count + 1
+			allowed = 1
+		end
+		redis.call('PEXPIRE', KEYS[1], math.ceil(period / 1000))
+
+		-- The oldest request frees the next slot, the newest one the whole limit
+		local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+		local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+		local reset_after = 0
+		if newest[2] then
+			reset_after = math.ceil((tonumber(newest[2]) + period - now) / 1000)
+		end
+
+		if allowed == 0 then
+			local retry_after = math.ceil((tonumber(oldest[2]) + period - now) / 1000)
+			return {0, 0, reset_after, retry_after}
+		end
+		return {1, limit - count, reset_after, 0}
+	`)
+
+	// gcraScript stores the theoretical arrival time (TAT) of the next request
+	// Each request moves it forward by period/limit; requests are allowed while it is at most one period ahead
+	// ARGV: limit, period_us
+	gcraScript = redis.NewScript(`
+		local limit = tonumber(ARGV[1])
+		local period = tonumber(ARGV[2])
+		local time = redis.call('TIME')
+		local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+		local interval = period / limit
+
+		local tat = tonumber(redis.call('GET', KEYS[1])) or now
+		if tat < now then
+			tat = now
+		end
+
+		local new_tat = tat + interval
+		local allow_at = new_tat - period
+		if allow_at > now then
+			return {0, 0, math.ceil((tat - now) / 1000), math.ceil((allow_at - now) / 1000)}
+		end
+
+		local reset_after = math.ceil((new_tat - now) / 1000)
+		redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', reset_after)
+		return {1, math.floor((now - allow_at) / interval), reset_after, 0}
+	`)
+)
 
 // RateLimitRepository implements repository.RedisRateLimitRepository
 type RateLimitRepository struct {
@@ -35,81 +116,63 @@ func NewRateLimitRepository(client *Client) *RateLimitRepository {
 	}
 }
 
-// IncrementUserRequest increments the request count for a user
-// Returns: current count, reset time, error
-func (r *RateLimitRepository) IncrementUserRequest(ctx context.Context, userID int64, window time.Duration) (int64, time.Time, error) {
-	key := r.keyBuilder.RateLimitUser(strconv.FormatInt(userID, 10))
-	return r.incrementRequest(ctx, key, window)
-}
-
-// IncrementIPRequest increments the request count for an IP address
-// Returns: current count, reset time, error
-func (r *RateLimitRepository) IncrementIPRequest(ctx context.Context, ipAddress string, window time.Duration) (int64, time.Time, error) {
-	key := r.keyBuilder.RateLimitIP(ipAddress)
-	return r.incrementRequest(ctx, key, window)
-}
-
-// GetUserRequestCount gets the current request count for a user
-func (r *RateLimitRepository) GetUserRequestCount(ctx context.Context, userID int64) (int64, error) {
-	key := r.keyBuilder.RateLimitUser(strconv.FormatInt(userID, 10))
-	return r.getRequestCount(ctx, key)
+// AllowUserRequest counts a request of a user against a rate limit
+func (r *RateLimitRepository) AllowUserRequest(ctx context.Context, limit model.RateLimit, userID int64) (*model.RateLimitResult, error) {
+	key := r.keyBuilder.RateLimitUser(limit.Policy, string(limit.Algorithm), strconv.FormatInt(userID, 10))
+	return r.allow(ctx, key, limit)
 }
 
-// GetIPRequestCount gets the current request count for an IP
-func (r *RateLimitRepository) GetIPRequestCount(ctx context.Context, ipAddress string) (int64, error) {
-	key := r.keyBuilder.RateLimitIP(ipAddress)
-	return r.getRequestCount(ctx, key)
+// AllowIPRequest counts a request from an IP address against a rate limit
+func (r *RateLimitRepository) AllowIPRequest(ctx context.Context, limit model.RateLimit, ipAddress string) (*model.RateLimitResult, error) {
+	key := r.keyBuilder.RateLimitIP(limit.Policy, string(limit.Algorithm), ipAddress)
+	return r.allow(ctx, key, limit)
 }
 
-// incrementRequest is a helper function that implements the rate limiting logic
-// using atomic increment with TTL
-func (r *RateLimitRepository) incrementRequest(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
-	// Use a pre-compiled Lua script (defined at package level) for better performance
-	// This prevents race conditions between INCR and TTL commands
-	// Returns: {count, ttl_seconds}
-	result, err := r.client.Eval(ctx, rateLimitScript, []string{key}, int(window.Seconds())).Result()
-	if err != nil {
-		return 0, time.Time{}, fmt.Errorf("failed to increment request count: %w", err)
-	}
-
-	// Parse result array
-	resultArray, ok := result.([]interface{})
-	if !ok || len(resultArray) != 2 {
-		return 0, time.Time{}, fmt.Errorf("unexpected result format from Lua script")
+// allow runs the script of the rate limit's algorithm on a key
+func (r *RateLimitRepository) allow(ctx context.Context, key string, limit model.RateLimit) (*model.RateLimitResult, error) {
+	if limit.Limit <= 0 || limit.Period <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: %d per %s", limit.Limit, limit.Period)
 	}
 
-	count, ok := resultArray[0].(int64)
-	if !ok {
-		return 0, time.Time{}, fmt.Errorf("unexpected count type from Lua script")
+	var result interface{}
+	var err error
+	switch limit.Algorithm {
+	case model.RateLimitFixedWindow:
+		result, err = fixedWindowScript.Run(ctx, r.client, []string{key}, limit.Limit, limit.Period.Milliseconds()).Result()
+	case model.RateLimitSlidingWindow:
+		result, err = slidingWindowScript.Run(ctx, r.client, []string{key}, limit.Limit, limit.Period.Microseconds(), uuid.NewString()).Result()
+	case model.RateLimitGCRA:
+		result, err = gcraScript.Run(ctx, r.client, []string{key}, limit.Limit, limit.Period.Microseconds()).Result()
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
 	}
-
-	ttlSeconds, ok := resultArray[1].(int64)
-	if !ok {
-		return 0, time.Time{}, fmt.Errorf("unexpected TTL type from Lua script")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count request: %w", err)
 	}
 
-	// Calculate reset time based on TTL from Redis
-	// Using time.Now() at this point is more accurate than after separate TTL call
-	resetTime := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
-
-	return count, resetTime, nil
+	return parseRateLimitResult(result)
 }
 
-// getRequestCount retrieves the current request count for a key
-func (r *RateLimitRepository) getRequestCount(ctx context.Context, key string) (int64, error) {
-	val, err := r.client.Get(ctx, key).Result()
-	if err != nil {
-		// Key doesn't exist means no requests yet
-		if err == redis.Nil {
-			return 0, nil
-		}
-		return 0, fmt.Errorf("failed to get request count: %w", err)
+// parseRateLimitResult converts the {allowed, remaining, reset_after_ms, retry_after_ms} script result
+func parseRateLimitResult(result interface{}) (*model.RateLimitResult, error) {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected result format from Lua script")
 	}
 
-	count, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse request count: %w", err)
+	numbers := make([]int64, len(values))
+	for i, value := range values {
+		number, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value type from Lua script")
+		}
+		numbers[i] = number
 	}
 
-	return count, nil
+	return &model.RateLimitResult{
+		Allowed:    numbers[0] == 1,
+		Remaining:  max(numbers[1], 0),
+		ResetAfter: time.Duration(numbers[2]) * time.Millisecond,
+		RetryAfter: time.Duration(numbers[3]) * time.Millisecond,
+	}, nil
 }
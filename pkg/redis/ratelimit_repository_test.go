@@ -0,0 +1,239 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Hamid207/ai-code-test1/internal/model"
+	"github.com/google/uuid"
+)
+
+// newTestClient connects to the Redis server at REDIS_TEST_ADDR (host:port)
+// Tests that run the Lua scripts are skipped when it is not set
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid REDIS_TEST_ADDR %q: %v", addr, err)
+	}
+
+	client, err := NewClient(Config{Host: host, Port: port, MaxConns: 4})
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRateLimitRepository_Scripts(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewRateLimitRepository(client)
+
+	tests := []struct {
+		name          string
+		algorithm     model.RateLimitAlgorithm
+		limit         int64
+		period        time.Duration
+		wantRemaining []int64 // Remaining after each allowed request of the initial burst
+		maxRetryAfter time.Duration
+	}{
+		{
+			name:          "fixed window",
+			algorithm:     model.RateLimitFixedWindow,
+			limit:         3,
+			period:        300 * time.Millisecond,
+			wantRemaining: []int64{2, 1, 0},
+			maxRetryAfter: 300 * time.Millisecond,
+		},
+		{
+			name:          "sliding window",
+			algorithm:     model.RateLimitSlidingWindow,
+			limit:         3,
+			period:        300 * time.Millisecond,
+			wantRemaining: []int64{2, 1, 0},
+			maxRetryAfter: 300 * time.Millisecond,
+		},
+		{
+			// Bursts of the limit, then one request per period/limit
+			name:          "gcra",
+			algorithm:     model.RateLimitGCRA,
+			limit:         3,
+			period:        300 * time.Millisecond,
+			wantRemaining: []int64{2, 1, 0},
+			maxRetryAfter: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := model.RateLimit{
+				Policy:    "test-" + uuid.NewString(),
+				Algorithm: tt.algorithm,
+				Limit:     tt.limit,
+				Period:    tt.period,
+			}
+
+			for i, want := range tt.wantRemaining {
+				result, err := repo.AllowIPRequest(ctx, limit, "192.0.2.1")
+				if err != nil {
+					t.Fatalf("request %d: %v", i+1, err)
+				}
+				if !result.Allowed {
+					t.Fatalf("request %d: denied within the limit", i+1)
+				}
+				if result.Remaining != want {
+					t.Errorf("request %d: remaining = %d, want %d", i+1, result.Remaining, want)
+				}
+				if result.ResetAfter <= 0 || result.ResetAfter > tt.period {
+					t.Errorf("request %d: reset after = %s, want within (0, %s]", i+1, result.ResetAfter, tt.period)
+				}
+			}
+
+			denied, err := repo.AllowIPRequest(ctx, limit, "192.0.2.1")
+			if err != nil {
+				t.Fatalf("request over the limit: %v", err)
+			}
+			if denied.Allowed {
+				t.Fatal("request over the limit was allowed")
+			}
+			if denied.Remaining != 0 {
+				t.Errorf("remaining = %d, want 0", denied.Remaining)
+			}
+			if denied.RetryAfter <= 0 || denied.RetryAfter > tt.maxRetryAfter {
+				t.Errorf("retry after = %s, want within (0, %s]", denied.RetryAfter, tt.maxRetryAfter)
+			}
+
+			// Other clients have their own budget
+			other, err := repo.AllowIPRequest(ctx, limit, "192.0.2.2")
+			if err != nil {
+				t.Fatalf("request of another client: %v", err)
+			}
+			if !other.Allowed {
+				t.Error("request of another client was denied")
+			}
+
+			// Rejected requests are not counted, so the client is allowed again once told to retry
+			time.Sleep(denied.RetryAfter + 20*time.Millisecond)
+			retried, err := repo.AllowIPRequest(ctx, limit, "192.0.2.1")
+			if err != nil {
+				t.Fatalf("retried request: %v", err)
+			}
+			if !retried.Allowed {
+				t.Errorf("retried request after %s was denied", denied.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimitRepository_SlidingWindowHasNoBoundaryBurst(t *testing.T) {
+	client := newTestClient(t)
+	repo := NewRateLimitRepository(client)
+	ctx := context.Background()
+
+	limit := model.RateLimit{
+		Policy:    "test-" + uuid.NewString(),
+		Algorithm: model.RateLimitSlidingWindow,
+		Limit:     2,
+		Period:    400 * time.Millisecond,
+	}
+
+	// One request early in the period and one late: a fixed window reset in between
+	// would allow two more, the sliding window only one once the first ages out
+	if _, err := repo.AllowUserRequest(ctx, limit, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if _, err := repo.AllowUserRequest(ctx, limit, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	first, err := repo.AllowUserRequest(ctx, limit, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.AllowUserRequest(ctx, limit, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Allowed || second.Allowed {
+		t.Errorf("allowed = %t, %t after the first request aged out, want true, false", first.Allowed, second.Allowed)
+	}
+}
+
+func TestRateLimitRepository_InvalidLimits(t *testing.T) {
+	// Rejected before any script runs, so no Redis is needed
+	repo := &RateLimitRepository{keyBuilder: NewKeyBuilder()}
+
+	tests := []struct {
+		name  string
+		limit model.RateLimit
+	}{
+		{"zero limit", model.RateLimit{Algorithm: model.RateLimitGCRA, Limit: 0, Period: time.Minute}},
+		{"zero period", model.RateLimit{Algorithm: model.RateLimitSlidingWindow, Limit: 10}},
+		{"unknown algorithm", model.RateLimit{Algorithm: "leaky_bucket", Limit: 10, Period: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.AllowIPRequest(context.Background(), tt.limit, "192.0.2.1"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseRateLimitResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  interface{}
+		want    model.RateLimitResult
+		wantErr bool
+	}{
+		{
+			name:   "allowed",
+			result: []interface{}{int64(1), int64(4), int64(1500), int64(0)},
+			want:   model.RateLimitResult{Allowed: true, Remaining: 4, ResetAfter: 1500 * time.Millisecond},
+		},
+		{
+			name:   "denied",
+			result: []interface{}{int64(0), int64(0), int64(900), int64(250)},
+			want:   model.RateLimitResult{ResetAfter: 900 * time.Millisecond, RetryAfter: 250 * time.Millisecond},
+		},
+		{
+			name:   "negative remaining is clamped",
+			result: []interface{}{int64(1), int64(-1), int64(10), int64(0)},
+			want:   model.RateLimitResult{Allowed: true, ResetAfter: 10 * time.Millisecond},
+		},
+		{name: "too few values", result: []interface{}{int64(1), int64(4)}, wantErr: true},
+		{name: "not a list", result: int64(1), wantErr: true},
+		{name: "non-integer value", result: []interface{}{int64(1), "4", int64(0), int64(0)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRateLimitResult(tt.result)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
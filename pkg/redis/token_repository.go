@@ -101,6 +101,22 @@ func (r *TokenRepository) GetRefreshToken(ctx context.Context, userID int64, tok
 	return tokenHash, nil
 }
 
+// ConsumeRefreshToken atomically retrieves and deletes a refresh token
+// Returns an empty hash if the token is missing, so only one concurrent caller can consume it
+func (r *TokenRepository) ConsumeRefreshToken(ctx context.Context, userID int64, tokenID string) (string, error) {
+	key := r.keyBuilder.RefreshToken(strconv.FormatInt(userID, 10), tokenID)
+
+	tokenHash, err := r.client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	return tokenHash, nil
+}
+
 // DeleteRefreshToken removes a refresh token from Redis
 func (r *TokenRepository) DeleteRefreshToken(ctx context.Context, userID int64, tokenID string) error {
 	key := r.keyBuilder.RefreshToken(strconv.FormatInt(userID, 10), tokenID)
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebhookEventRepository implements repository.RedisWebhookEventRepository
+type WebhookEventRepository struct {
+	client     *Client
+	keyBuilder *KeyBuilder
+	logger     Logger
+}
+
+// NewWebhookEventRepository creates a new WebhookEventRepository
+func NewWebhookEventRepository(client *Client) *WebhookEventRepository {
+	return &WebhookEventRepository{
+		client:     client,
+		keyBuilder: NewKeyBuilder(),
+		logger:     defaultLogger,
+	}
+}
+
+// WithLogger sets a custom logger for this repository
+func (r *WebhookEventRepository) WithLogger(logger Logger) *WebhookEventRepository {
+	r.logger = logger
+	return r
+}
+
+// ClaimEvent records an event as processed for ttl (SET NX)
+// Returns false if the event was already claimed, i.e. it is a replay or a redelivery
+func (r *WebhookEventRepository) ClaimEvent(ctx context.Context, source, eventID string, ttl time.Duration) (bool, error) {
+	key := r.keyBuilder.WebhookEvent(source, eventID)
+
+	claimed, err := r.client.SetNX(ctx, key, time.Now().Unix(), ttl).Result()
+	if err != nil {
+		r.logger.Error("failed to claim webhook event",
+			zap.String("source", source),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// ReleaseEvent forgets a claimed event
+func (r *WebhookEventRepository) ReleaseEvent(ctx context.Context, source, eventID string) error {
+	key := r.keyBuilder.WebhookEvent(source, eventID)
+
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		r.logger.Error("failed to release webhook event",
+			zap.String("source", source),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to release webhook event: %w", err)
+	}
+
+	return nil
+}
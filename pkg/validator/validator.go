@@ -2,8 +2,11 @@ package validator
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Email validation regex (simplified but covers most cases)
@@ -35,43 +38,107 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
-// ValidateAppleID validates Apple ID format
-func ValidateAppleID(appleID string) error {
-	if appleID == "" {
-		return fmt.Errorf("apple_id is required")
+// providerRegex matches identity provider names, which must be safe in URLs and env var names
+// It is the only definition: config validates OIDC_PROVIDERS names with ValidateProvider
+var providerRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{1,31}$`)
+
+// ValidateProvider validates an identity provider name
+func ValidateProvider(provider string) error {
+	if provider == "" {
+		return fmt.Errorf("provider is required")
 	}
 
-	// Trim spaces
-	appleID = strings.TrimSpace(appleID)
+	if !providerRegex.MatchString(provider) {
+		return fmt.Errorf("invalid provider name")
+	}
 
-	// Check length
-	if len(appleID) > 255 {
-		return fmt.Errorf("apple_id is too long (max 255 characters)")
+	return nil
+}
+
+// ValidateSubject validates the subject identifier asserted by an identity provider
+func ValidateSubject(subject string) error {
+	if strings.TrimSpace(subject) == "" {
+		return fmt.Errorf("subject is required")
 	}
 
-	if len(appleID) < 10 {
-		return fmt.Errorf("apple_id is too short")
+	// OpenID Connect limits subjects to 255 ASCII characters
+	if len(subject) > 255 {
+		return fmt.Errorf("subject is too long (max 255 characters)")
 	}
 
 	return nil
 }
 
-// ValidateGoogleID validates Google ID format
-func ValidateGoogleID(googleID string) error {
-	if googleID == "" {
-		return fmt.Errorf("google_id is required")
+// ValidateDisplayName validates a user's display name
+func ValidateDisplayName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("display name cannot be blank")
 	}
 
-	// Trim spaces
-	googleID = strings.TrimSpace(googleID)
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("display name must be valid UTF-8")
+	}
+
+	if utf8.RuneCountInString(name) > 100 {
+		return fmt.Errorf("display name is too long (max 100 characters)")
+	}
+
+	// Control characters (newlines, escapes) could break rendering and logs
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("display name contains control characters")
+		}
+	}
+
+	return nil
+}
+
+// ValidateAvatarURL validates the URL of a user's profile picture
+// Only absolute https URLs are accepted so clients never load mixed or script content
+func ValidateAvatarURL(avatarURL string) error {
+	if len(avatarURL) > 2048 {
+		return fmt.Errorf("avatar URL is too long (max 2048 characters)")
+	}
+
+	parsed, err := url.Parse(avatarURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("avatar URL must be an absolute https URL")
+	}
+
+	if parsed.User != nil {
+		return fmt.Errorf("avatar URL cannot contain credentials")
+	}
+
+	return nil
+}
+
+// localeRegex matches BCP 47 language tags such as "en", "en-US" or "zh-Hant-TW"
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$`)
+
+// ValidateLocale validates a BCP 47 language tag
+func ValidateLocale(locale string) error {
+	if len(locale) > 35 {
+		return fmt.Errorf("locale is too long (max 35 characters)")
+	}
+
+	if !localeRegex.MatchString(locale) {
+		return fmt.Errorf("invalid locale (expected a language tag such as en-US)")
+	}
+
+	return nil
+}
+
+// roleNameRegex matches role names such as "admin" or "support-agent"
+var roleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
 
-	// Check length (Google IDs are typically 21 numeric digits)
-	if len(googleID) > 255 {
-		return fmt.Errorf("google_id is too long (max 255 characters)")
+// ValidateRoleName validates a role name
+func ValidateRoleName(name string) error {
+	if name == "" {
+		return fmt.Errorf("role name is required")
 	}
 
-	if len(googleID) < 10 {
-		return fmt.Errorf("google_id is too short (min 10 characters)")
+	if !roleNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid role name (2-32 lowercase letters, digits, dashes or underscores)")
 	}
 
 	return nil